@@ -0,0 +1,48 @@
+package pipeline
+
+import "time"
+
+// alarm is a reusable timer that fires at a specific time
+type alarm struct {
+	clock Clock
+	timer Timer
+	at    time.Time
+}
+
+// newAlarm creates an alarm that is not set
+func newAlarm(clock Clock) *alarm {
+	timer := clock.NewTimer(time.Hour)
+	timer.Stop()
+	return &alarm{clock: clock, timer: timer}
+}
+
+// C returns the chan the alarm fires on
+func (a *alarm) C() <-chan time.Time {
+	return a.timer.C()
+}
+
+// set makes the alarm fire at the given time instead of the time it was previously set to
+func (a *alarm) set(at time.Time) {
+	if at.Equal(a.at) {
+		return
+	}
+	// Make sure a stale time can't be received after the reset
+	if !a.timer.Stop() {
+		select {
+		case <-a.timer.C():
+		default:
+		}
+	}
+	a.at = at
+	a.timer.Reset(at.Sub(a.clock.Now()))
+}
+
+// fired must be called after the time is received from C
+func (a *alarm) fired() {
+	a.at = time.Time{}
+}
+
+// stop releases the timer
+func (a *alarm) stop() {
+	a.timer.Stop()
+}
@@ -1,14 +1,25 @@
 package pipeline
 
+import "context"
+
 // Buffer creates a buffered channel that will close after the input
 // is closed and the buffer is fully drained
-func Buffer[Item any](size int, in <-chan Item) <-chan Item {
+func Buffer[Item any](size int, in <-chan Item, opts ...Option) <-chan Item {
+	c := newConfig("Buffer", opts)
 	buffer := make(chan Item, size)
-	go func() {
-		for i := range in {
-			buffer <- i
+	connect(c, buffer, in)
+	goStage(context.Background(), c.name, c.kind, func() {
+		for {
+			i, open := receive(c.node, in)
+			if !open {
+				break
+			}
+			c.metrics.In(c.name)
+			deliver(c.node, buffer, i)
+			c.metrics.Out(c.name)
 		}
 		close(buffer)
-	}()
+		c.closed()
+	})
 	return buffer
 }
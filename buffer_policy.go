@@ -0,0 +1,90 @@
+package pipeline
+
+import "context"
+
+// OverflowPolicy decides what BufferWithPolicy does with an item when its buffer is full
+type OverflowPolicy int
+
+const (
+	// Block waits for room in the buffer, like Buffer, so the upstream stages are slowed down to the pace of the downstream ones.
+	Block OverflowPolicy = iota
+	// DropNewest drops the item that was just received.
+	DropNewest
+	// DropOldest drops the oldest item in the buffer to make room for the item that was just received.
+	DropOldest
+)
+
+// WithDropped calls `f` with every item BufferWithPolicy drops.
+// `Item` must be the type of the items of the stage, otherwise the stage panics when it is created.
+// It is only read by BufferWithPolicy, and any other stage it is given to panics when it is created.
+func WithDropped[Item any](f func(Item)) Option {
+	return func(c *config) {
+		c.dropped = f
+		c.stageOption("WithDropped")
+	}
+}
+
+// BufferWithPolicy creates a buffered channel like Buffer, that applies `policy` when it is full, so a slow consumer can't slow down the upstream stages.
+// Every dropped item is reported to the Metrics of the stage if they implement DropMetrics, and passed to the func set WithDropped.
+// Items dropped to make room for the next one were counted out when they entered the buffer, so the next one isn't,
+// and the number of items in is always the number out plus the number dropped.
+// The channel is closed after the input is closed and the buffer is fully drained.
+func BufferWithPolicy[Item any](size int, policy OverflowPolicy, in <-chan Item, opts ...Option) <-chan Item {
+	c := newConfig("BufferWithPolicy", opts, "WithDropped")
+	buffer := make(chan Item, size)
+	connect(c, buffer, in)
+	// Without a buffer, the item that was just received is the oldest one
+	if size < 1 && policy == DropOldest {
+		policy = DropNewest
+	}
+	dropped := callback[Item](c.dropped, "WithDropped")
+	drop := func(i Item) {
+		reportDrop(c.metrics, c.name)
+		if dropped != nil {
+			dropped(i)
+		}
+	}
+	goStage(context.Background(), c.name, c.kind, func() {
+		defer c.closed()
+		defer close(buffer)
+		for {
+			i, open := receive(c.node, in)
+			if !open {
+				return
+			}
+			c.metrics.In(c.name)
+			switch policy {
+			case DropNewest:
+				select {
+				case buffer <- i:
+				default:
+					drop(i)
+					continue
+				}
+			case DropOldest:
+				// The downstream stage may take the oldest item first, in which case there is room for the new one
+				var evicted bool
+				for sent := false; !sent; {
+					select {
+					case buffer <- i:
+						sent = true
+					default:
+						select {
+						case oldest := <-buffer:
+							drop(oldest)
+							evicted = true
+						default:
+						}
+					}
+				}
+				if evicted {
+					continue
+				}
+			default:
+				deliver(c.node, buffer, i)
+			}
+			c.metrics.Out(c.name)
+		}
+	})
+	return buffer
+}
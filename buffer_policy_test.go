@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline/v2/pipelinetest"
+)
+
+func TestBufferWithPolicy(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		size   int
+		policy OverflowPolicy
+	}
+	type want struct {
+		// received is the number of items received before the buffer blocks
+		received int64
+		out      []int
+		dropped  []int
+	}
+	for _, test := range []struct {
+		name string
+		args args
+		want want
+	}{{
+		name: "Block waits for room in the buffer",
+		args: args{size: 2, policy: Block},
+		want: want{received: 3, out: []int{1, 2, 3, 4, 5}},
+	}, {
+		name: "DropNewest drops the items received while the buffer is full",
+		args: args{size: 2, policy: DropNewest},
+		want: want{received: 5, out: []int{1, 2}, dropped: []int{3, 4, 5}},
+	}, {
+		name: "DropOldest drops the oldest items in the buffer",
+		args: args{size: 2, policy: DropOldest},
+		want: want{received: 5, out: []int{4, 5}, dropped: []int{1, 2, 3}},
+	}, {
+		name: "DropOldest without a buffer drops the items that are not received straight away",
+		args: args{size: 0, policy: DropOldest},
+		want: want{received: 5, dropped: []int{1, 2, 3, 4, 5}},
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			g := NewGraph()
+			var mu sync.Mutex
+			var dropped []int
+			out := BufferWithPolicy(test.args.size, test.args.policy, Emit(1, 2, 3, 4, 5), WithGraph(g), WithDropped(func(i int) {
+				mu.Lock()
+				defer mu.Unlock()
+				dropped = append(dropped, i)
+			}))
+
+			// Nothing reads from the buffer until every item is dropped or the buffer blocks
+			waitFor(t, func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				s := g.Status()[0]
+				return s.In == test.want.received && len(dropped) == len(test.want.dropped) && (test.args.policy != Block || s.BlockedOnSend == 1)
+			})
+			if out := pipelinetest.Collect(t, out, time.Second); !reflect.DeepEqual(out, test.want.out) {
+				t.Errorf("out = %v, want %v", out, test.want.out)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if !reflect.DeepEqual(dropped, test.want.dropped) {
+				t.Errorf("dropped = %v, want %v", dropped, test.want.dropped)
+			}
+			stats := g.Stats()[0]
+			if stats.Dropped != int64(len(test.want.dropped)) {
+				t.Errorf("Dropped = %d, want %d", stats.Dropped, len(test.want.dropped))
+			}
+			// Every item received is either passed on or dropped
+			if stats.In != stats.Out+stats.Dropped {
+				t.Errorf("In = %d, want Out + Dropped = %d + %d", stats.In, stats.Out, stats.Dropped)
+			}
+		})
+	}
+}
+
+func TestBufferWithPolicyDroppedOfAnotherType(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("BufferWithPolicy did not panic")
+		}
+	}()
+	BufferWithPolicy(1, DropNewest, make(chan int), WithDropped(func(string) {}))
+}
+
+func TestWithDroppedOfAnotherStage(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Buffer did not panic")
+		}
+	}()
+	// Only BufferWithPolicy drops items
+	Buffer(1, make(chan int), WithDropped(func(int) {}))
+}
@@ -6,28 +6,38 @@ import (
 
 // Cancel passes an `Item any` from the `in <-chan Item` directly to the out `<-chan Item` until the `Context` is canceled.
 // After the context is canceled, everything from `in <-chan Item` is sent to the `cancel` func instead with the `ctx.Err()`.
-func Cancel[Item any](ctx context.Context, cancel func(Item, error), in <-chan Item) <-chan Item {
+func Cancel[Item any](ctx context.Context, cancel func(Item, error), in <-chan Item, opts ...Option) <-chan Item {
+	c := newConfig("Cancel", opts)
 	out := make(chan Item)
-	go func() {
+	connect(c, out, in)
+	goStage(ctx, c.name, c.kind, func() {
+		defer c.closed()
 		defer close(out)
 		for {
+			c.node.blockedOnReceive(1)
 			select {
 			// When the context isn't canceld, pass everything to the out chan
 			// until in is closed
 			case i, open := <-in:
+				c.node.blockedOnReceive(-1)
 				if !open {
 					return
 				}
-				out <- i
+				c.metrics.In(c.name)
+				deliver(c.node, out, i)
+				c.metrics.Out(c.name)
 			// When the context is canceled, pass all ins to the
 			// cancel fun until in is closed
 			case <-ctx.Done():
+				c.node.blockedOnReceive(-1)
 				for i := range in {
+					c.metrics.In(c.name)
+					c.metrics.Cancel(c.name, ctx.Err())
 					cancel(i, ctx.Err())
 				}
 				return
 			}
 		}
-	}()
+	})
 	return out
 }
@@ -0,0 +1,287 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// settle is how long the stages behind the barriers of a Checkpointer must be quiet before they are snapshotted
+const settle = 10 * time.Millisecond
+
+// Snapshotter is implemented by the state of a stateful stage, like the SeenStore of Distinct or a Processor that aggregates its inputs,
+// so it can be saved by a Checkpointer and restored after a restart.
+type Snapshotter interface {
+	// Snapshot encodes the current state.
+	Snapshot() ([]byte, error)
+
+	// Restore replaces the current state with one encoded by Snapshot.
+	Restore(state []byte) error
+}
+
+// Checkpoint is a consistent snapshot of the state of the stages of a pipeline
+type Checkpoint struct {
+	// ID increases with every checkpoint of a Checkpointer, including the ones taken after it was restored.
+	ID   int64     `json:"id"`
+	Time time.Time `json:"time"`
+	// States holds the snapshot of every Snapshotter, by the name it was registered with.
+	States map[string][]byte `json:"states"`
+}
+
+// CheckpointStore saves the checkpoints of a Checkpointer.
+// Implement CheckpointStore to keep the checkpoints somewhere that outlives the pipeline, like a file or a bucket.
+type CheckpointStore interface {
+	// Save saves a checkpoint, which becomes the latest one.
+	Save(ctx context.Context, cp Checkpoint) error
+
+	// Latest returns the latest checkpoint that was saved, or false if there is none.
+	Latest(ctx context.Context) (Checkpoint, bool, error)
+}
+
+// Checkpointer takes consistent snapshots of the stateful stages of a pipeline, and restores them when the pipeline restarts.
+//
+// Sources are passed through a Barrier. To take a checkpoint, every barrier stops letting items through,
+// then the Checkpointer waits for the stages after them to pass on every item they received.
+// Once they are quiet, the state of every registered Snapshotter reflects exactly the items that passed the barriers.
+// Stages that hold items for a while, like Collect, Debounce, Sample, Zip and JoinByKey, are waited on until they pass them on,
+// so a checkpoint pauses the sources for up to the longest of those waits, like the maxDuration of Collect or the window of JoinByKey.
+// The stages after the barriers must be in the Graph of the Checkpointer, e.g. with WithGraph.
+// Stages that can't be configured with options, like Merge, must be added to it with Register.
+//
+// Only the registered Snapshotters are in a checkpoint. Stages that keep state across items start over after a restart,
+// like the latest items of CombineLatest, the counts of Take and Skip, and the keys of Distinct.
+// Use DistinctStore and register its SeenStore to keep the keys, and keep the other stages out of pipelines that are checkpointed.
+//
+// A source before a barrier may have read items the barrier didn't let through yet, so its position can't be snapshotted consistently.
+// Instead, register a Snapshotter that records the position of the items passed by the barrier, like a Processor right after it.
+type Checkpointer struct {
+	graph *Graph
+	store CheckpointStore
+	valve *Valve
+	c     *config
+
+	mu           sync.Mutex
+	last         int64
+	barriers     []*barrier
+	snapshotters map[string]Snapshotter
+}
+
+// barrier is the state of a Barrier stage
+type barrier struct {
+	node *node
+	done int32
+}
+
+// NewCheckpointer creates a Checkpointer of the stages in `g`, that saves its checkpoints to `store`.
+// Pass WithClock to control the interval of Run, and how long the stages must be quiet before they are snapshotted.
+func NewCheckpointer(g *Graph, store CheckpointStore, opts ...Option) *Checkpointer {
+	return &Checkpointer{
+		graph:        g,
+		store:        store,
+		valve:        NewValve(),
+		c:            newConfig("Checkpointer", opts),
+		snapshotters: make(map[string]Snapshotter),
+	}
+}
+
+// Register adds the state of a stage to the checkpoints under `name`, which must be unique and stay the same across restarts
+func (cp *Checkpointer) Register(name string, s Snapshotter) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.snapshotters[name] = s
+}
+
+// Restore restores every registered Snapshotter from the latest checkpoint in the store.
+// Call it before the pipeline starts. It returns false if there is no checkpoint to restore from.
+// Snapshotters without a state in the checkpoint are left as they are.
+func (cp *Checkpointer) Restore(ctx context.Context) (bool, error) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	latest, ok, err := cp.store.Latest(ctx)
+	if err != nil || !ok {
+		return false, err
+	}
+	for name, s := range cp.snapshotters {
+		if state, ok := latest.States[name]; ok {
+			if err := s.Restore(state); err != nil {
+				return false, fmt.Errorf("restoring %s from checkpoint %d: %w", name, latest.ID, err)
+			}
+		}
+	}
+	cp.last = latest.ID
+	return true, nil
+}
+
+// Checkpoint takes a checkpoint and saves it to the store.
+// The barriers let no items through until it returns, so it returns an error if the stages don't become quiet before the context is canceled.
+func (cp *Checkpointer) Checkpoint(ctx context.Context) (Checkpoint, error) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.valve.Pause()
+	defer cp.valve.Resume()
+	if err := cp.quiesce(ctx); err != nil {
+		return Checkpoint{}, err
+	}
+	checkpoint := Checkpoint{
+		ID:     cp.last + 1,
+		Time:   cp.c.clock.Now(),
+		States: make(map[string][]byte, len(cp.snapshotters)),
+	}
+	for name, s := range cp.snapshotters {
+		state, err := s.Snapshot()
+		if err != nil {
+			return Checkpoint{}, fmt.Errorf("snapshotting %s: %w", name, err)
+		}
+		checkpoint.States[name] = state
+	}
+	if err := cp.store.Save(ctx, checkpoint); err != nil {
+		return Checkpoint{}, err
+	}
+	cp.last = checkpoint.ID
+	return checkpoint, nil
+}
+
+// Run takes a checkpoint every `interval` until the context is canceled.
+// The errors of the checkpoints that failed are sent to the returned chan, which is closed once the context is canceled.
+// The chan must be read, or no more checkpoints are taken.
+func (cp *Checkpointer) Run(ctx context.Context, interval time.Duration) <-chan error {
+	out := make(chan error)
+	goStage(ctx, cp.c.name, cp.c.kind, func() {
+		defer close(out)
+		ticker := cp.c.clock.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+				if _, err := cp.Checkpoint(ctx); err != nil {
+					select {
+					case <-ctx.Done():
+						return
+					case out <- err:
+					}
+				}
+			}
+		}
+	})
+	return out
+}
+
+// quiesce waits until every barrier is paused and the stages after them are quiet
+func (cp *Checkpointer) quiesce(ctx context.Context) error {
+	var last []StageStatus
+	ticker := cp.c.clock.NewTicker(settle)
+	defer ticker.Stop()
+	for {
+		status, quiet := cp.quiet()
+		if quiet && sameCounts(last, status) {
+			return nil
+		}
+		if quiet {
+			last = status
+		} else {
+			last = nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for the stages to be quiet: %w", context.Cause(ctx))
+		case <-ticker.C():
+		}
+	}
+}
+
+// quiet returns the status of the stages after the barriers, and whether they are quiet and no barrier is letting an item through
+func (cp *Checkpointer) quiet() ([]StageStatus, bool) {
+	var from []*node
+	for _, b := range cp.barriers {
+		// A barrier waiting for an item will hold it once it is received
+		if s := b.node.status(); atomic.LoadInt32(&b.done) == 0 && !s.Paused && s.BlockedOnReceive == 0 {
+			return nil, false
+		}
+		from = append(from, b.node)
+	}
+	stages := cp.graph.downstream(from)
+	status := make([]StageStatus, len(stages))
+	for i, n := range stages {
+		s := n.status()
+		if s.InFlight > 0 || s.BlockedOnSend > 0 || s.Held > 0 || s.Buffered > 0 || n.pending() > 0 {
+			return nil, false
+		}
+		status[i] = s
+	}
+	return status, true
+}
+
+// sameCounts returns true if the stages of a and b received, sent and canceled the same number of items
+func sameCounts(a, b []StageStatus) bool {
+	if a == nil || len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].In != b[i].In || a[i].Out != b[i].Out || a[i].Canceled != b[i].Canceled {
+			return false
+		}
+	}
+	return true
+}
+
+// downstream returns the nodes that read from the nodes in `from`, directly or through other nodes
+func (g *Graph) downstream(from []*node) []*node {
+	nodes := g.watched()
+	readers := make(map[any][]*node, len(nodes))
+	for _, n := range nodes {
+		for _, in := range n.inputs() {
+			readers[in] = append(readers[in], n)
+		}
+	}
+	seen := make(map[*node]bool)
+	var found []*node
+	for len(from) > 0 {
+		n := from[0]
+		from = from[1:]
+		for _, r := range readers[n.output()] {
+			if !seen[r] {
+				seen[r] = true
+				found = append(found, r)
+				from = append(from, r)
+			}
+		}
+	}
+	return found
+}
+
+// Barrier passes the items from `in <-chan Item` to the out channel, except while the Checkpointer takes a checkpoint.
+// Pass every source of the pipeline through a barrier, so nothing enters the pipeline while it is snapshotted.
+// The barrier is added to the Graph of the Checkpointer.
+// If the context is canceled, the barrier is no longer applied, so the pipeline can shut down.
+func Barrier[Item any](ctx context.Context, cp *Checkpointer, in <-chan Item, opts ...Option) <-chan Item {
+	c := newConfig("Barrier", append([]Option{WithGraph(cp.graph)}, opts...))
+	out := make(chan Item)
+	connect(c, out, in)
+	b := &barrier{node: c.node}
+	cp.mu.Lock()
+	cp.barriers = append(cp.barriers, b)
+	cp.mu.Unlock()
+	goStage(ctx, c.name, c.kind, func() {
+		defer c.closed()
+		defer close(out)
+		// A closed barrier lets nothing through, so it doesn't hold up checkpoints
+		defer atomic.StoreInt32(&b.done, 1)
+		for {
+			waitForValve(ctx, c, cp.valve)
+			i, open := receive(c.node, in)
+			if !open {
+				return
+			}
+			c.metrics.In(c.name)
+			// A checkpoint may have started while waiting for the item
+			waitForValve(ctx, c, cp.valve)
+			deliver(c.node, out, i)
+			c.metrics.Out(c.name)
+		}
+	})
+	return out
+}
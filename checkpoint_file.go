@@ -0,0 +1,117 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// checkpointPrefix and checkpointSuffix surround the zero padded ID in the name of a checkpoint file, so the names sort by ID
+const (
+	checkpointPrefix = "checkpoint-"
+	checkpointSuffix = ".json"
+)
+
+// FileCheckpointStore is a CheckpointStore that keeps the latest checkpoint as a JSON file in a local directory
+type FileCheckpointStore struct {
+	dir string
+}
+
+// NewFileCheckpointStore creates a FileCheckpointStore that keeps its checkpoints in `dir`, which is created if it doesn't exist
+func NewFileCheckpointStore(dir string) *FileCheckpointStore {
+	return &FileCheckpointStore{dir: dir}
+}
+
+// Save writes the checkpoint to a temporary file, syncs it and renames it, so a crash never leaves a partial checkpoint behind.
+// The older checkpoints are removed once it is saved.
+func (s *FileCheckpointStore) Save(_ context.Context, cp Checkpoint) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(s.dir, "."+checkpointPrefix+"*")
+	if err != nil {
+		return err
+	}
+	// Once it is renamed, removing the temporary file does nothing
+	defer os.Remove(f.Name())
+	if err := json.NewEncoder(f).Encode(cp); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	name := filepath.Join(s.dir, fmt.Sprintf("%s%020d%s", checkpointPrefix, cp.ID, checkpointSuffix))
+	if err := os.Rename(f.Name(), name); err != nil {
+		return err
+	}
+	if err := syncDir(s.dir); err != nil {
+		return err
+	}
+	names, err := s.checkpoints()
+	if err != nil {
+		return err
+	}
+	for _, n := range names {
+		if n != name {
+			if err := os.Remove(n); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Latest reads the checkpoint with the highest ID
+func (s *FileCheckpointStore) Latest(context.Context) (Checkpoint, bool, error) {
+	names, err := s.checkpoints()
+	if err != nil || len(names) == 0 {
+		return Checkpoint{}, false, err
+	}
+	f, err := os.Open(names[len(names)-1])
+	if err != nil {
+		return Checkpoint{}, false, err
+	}
+	defer f.Close()
+	var cp Checkpoint
+	if err := json.NewDecoder(f).Decode(&cp); err != nil {
+		return Checkpoint{}, false, fmt.Errorf("reading %s: %w", f.Name(), err)
+	}
+	return cp, true, nil
+}
+
+// checkpoints returns the paths of the checkpoint files in the directory, from the lowest ID to the highest
+func (s *FileCheckpointStore) checkpoints() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), checkpointPrefix) && strings.HasSuffix(e.Name(), checkpointSuffix) {
+			names = append(names, filepath.Join(s.dir, e.Name()))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// syncDir syncs a directory, so the files renamed into it survive a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,229 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline/v2/pipelinetest"
+)
+
+// tally is a stateful Processor that records the last input it processed and the sum of its inputs
+type tally struct {
+	mu   sync.Mutex
+	Last int `json:"last"`
+	Sum  int `json:"sum"`
+}
+
+func (t *tally) Process(_ context.Context, i int) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Last, t.Sum = i, t.Sum+i
+	return i, nil
+}
+
+func (t *tally) Cancel(int, error) {}
+
+func (t *tally) Snapshot() ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return json.Marshal(t)
+}
+
+func (t *tally) Restore(state []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return json.Unmarshal(state, t)
+}
+
+func (t *tally) get() (last, sum int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.Last, t.Sum
+}
+
+// count emits the numbers from `from` to `to`, until the context is canceled
+func count(ctx context.Context, from, to int) <-chan int {
+	out := make(chan int)
+	go func() {
+		defer close(out)
+		for i := from; i <= to; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case out <- i:
+			}
+			time.Sleep(time.Millisecond / 10)
+		}
+	}()
+	return out
+}
+
+// runTally runs a pipeline that counts to 200 from after the position of the latest checkpoint.
+// The position and the sum are tallied on either side of a Collect, which holds on to the numbers it collects.
+func runTally(ctx context.Context, t *testing.T, store CheckpointStore) (cp *Checkpointer, position, sum *tally, done <-chan struct{}) {
+	g := NewGraph()
+	cp = NewCheckpointer(g, store)
+	position, sum = &tally{}, &tally{}
+	cp.Register("position", position)
+	cp.Register("sum", sum)
+	if _, err := cp.Restore(ctx); err != nil {
+		t.Fatalf("Restore() = %s", err)
+	}
+	last, _ := position.get()
+	p := Barrier(ctx, cp, count(ctx, last+1, 200))
+	p = Process(ctx, position, p, WithGraph(g), WithName("position"))
+	p = Split(Collect(ctx, 3, 10*time.Millisecond, p, WithGraph(g)), WithGraph(g))
+	p = Process(ctx, sum, p, WithGraph(g), WithName("sum"))
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		Drain(p)
+	}()
+	return cp, position, sum, finished
+}
+
+func TestCheckpointerRestoresAfterRestart(t *testing.T) {
+	t.Parallel()
+
+	store := NewFileCheckpointStore(t.TempDir())
+
+	// Checkpoint the first run a few times, then crash it
+	ctx, cancel := context.WithCancel(context.Background())
+	cp, _, _, done := runTally(ctx, t, store)
+	var last Checkpoint
+	for i := 0; i < 3; i++ {
+		time.Sleep(5 * time.Millisecond)
+		checkpoint, err := cp.Checkpoint(ctx)
+		if err != nil {
+			t.Fatalf("Checkpoint() = %s", err)
+		}
+		// Every number that passed the barrier was added to the sum
+		var position, sum tally
+		json.Unmarshal(checkpoint.States["position"], &position)
+		json.Unmarshal(checkpoint.States["sum"], &sum)
+		if want := position.Last * (position.Last + 1) / 2; sum.Sum != want || sum.Last != position.Last {
+			t.Errorf("checkpoint %d has a sum of %d up to %d, want %d up to %d", checkpoint.ID, sum.Sum, sum.Last, want, position.Last)
+		}
+		last = checkpoint
+	}
+	cancel()
+	<-done
+	if last.ID != 3 {
+		t.Errorf("last checkpoint = %d, want 3", last.ID)
+	}
+
+	// The second run picks up from the last checkpoint
+	cp, _, sum, done := runTally(context.Background(), t, store)
+	<-done
+	if last, sum := sum.get(); last != 200 || sum != 200*201/2 {
+		t.Errorf("sum = %d up to %d, want %d up to 200", sum, last, 200*201/2)
+	}
+	checkpoint, err := cp.Checkpoint(context.Background())
+	if err != nil {
+		t.Fatalf("Checkpoint() after the pipeline is done = %s", err)
+	}
+	if checkpoint.ID != 4 {
+		t.Errorf("checkpoint after the restart = %d, want 4", checkpoint.ID)
+	}
+}
+
+func TestCheckpointerWaitsForQuiet(t *testing.T) {
+	t.Parallel()
+
+	// A stage that never finishes processing keeps the checkpoint from being taken
+	g := NewGraph()
+	cp := NewCheckpointer(g, NewFileCheckpointStore(t.TempDir()))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	blocked := pipelinetest.NewProcessor(pipelinetest.Block[int, int]())
+	out := Process(ctx, blocked, Barrier(ctx, cp, Emit(1, 2)), WithGraph(g))
+	waitFor(t, func() bool {
+		return g.Status()[1].InFlight == 1
+	})
+
+	timeout, cancelTimeout := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancelTimeout()
+	if _, err := cp.Checkpoint(timeout); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Checkpoint() = %v, want %s", err, context.DeadlineExceeded)
+	}
+
+	// The barrier lets the items through again afterwards
+	cancel()
+	Drain(out)
+	if canceled := blocked.Canceled(); len(canceled) != 2 {
+		t.Errorf("canceled = %v, want both items", canceled)
+	}
+}
+
+func TestCheckpointerRun(t *testing.T) {
+	t.Parallel()
+
+	clock := pipelinetest.NewFakeClock(time.Now())
+	dir := t.TempDir()
+	cp := NewCheckpointer(NewGraph(), NewFileCheckpointStore(dir), WithClock(clock))
+	cp.Register("sum", &tally{Sum: 3})
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := cp.Run(ctx, time.Minute)
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	// The stages must be quiet for a while before they are snapshotted
+	clock.BlockUntil(2)
+	clock.Advance(settle)
+	waitFor(t, func() bool {
+		_, err := os.Stat(filepath.Join(dir, "checkpoint-00000000000000000001.json"))
+		return err == nil
+	})
+	cancel()
+	if err, open := <-errs; open {
+		t.Errorf("Run() sent %s", err)
+	}
+
+	restored := &tally{}
+	cp = NewCheckpointer(NewGraph(), NewFileCheckpointStore(dir))
+	cp.Register("sum", restored)
+	if ok, err := cp.Restore(context.Background()); !ok || err != nil {
+		t.Fatalf("Restore() = %t, %v", ok, err)
+	}
+	if _, sum := restored.get(); sum != 3 {
+		t.Errorf("restored sum = %d, want 3", sum)
+	}
+}
+
+func TestFileCheckpointStore(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "checkpoints")
+	store := NewFileCheckpointStore(dir)
+	ctx := context.Background()
+	if _, ok, err := store.Latest(ctx); ok || err != nil {
+		t.Fatalf("Latest() of an empty store = %t, %v", ok, err)
+	}
+
+	for id := int64(1); id <= 3; id++ {
+		if err := store.Save(ctx, Checkpoint{ID: id, States: map[string][]byte{"a": {byte(id)}}}); err != nil {
+			t.Fatalf("Save(%d) = %s", id, err)
+		}
+	}
+	// A temporary file left by a crash is ignored
+	if err := os.WriteFile(filepath.Join(dir, ".checkpoint-123"), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	latest, ok, err := NewFileCheckpointStore(dir).Latest(ctx)
+	if !ok || err != nil {
+		t.Fatalf("Latest() = %t, %v", ok, err)
+	}
+	if latest.ID != 3 || latest.States["a"][0] != 3 {
+		t.Errorf("Latest() = %+v, want checkpoint 3", latest)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "checkpoint-*")); len(files) != 1 {
+		t.Errorf("checkpoint files = %v, want only the latest", files)
+	}
+}
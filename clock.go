@@ -0,0 +1,21 @@
+package pipeline
+
+import "github.com/deliveryhero/pipeline/v2/internal/clock"
+
+// Clock tells the time and creates the timers and tickers of the stages that wait, like Delay, Collect and Debounce.
+// Stages use the clock of the time package, unless they are configured WithClock.
+// Use `pipelinetest.NewFakeClock` to control the time in tests instead of sleeping.
+type Clock = clock.Clock
+
+// Timer is like a `time.Timer`, see Clock
+type Timer = clock.Timer
+
+// Ticker is like a `time.Ticker`, see Clock
+type Ticker = clock.Ticker
+
+// WithClock makes a stage tell the time and wait with `c`
+func WithClock(c Clock) Option {
+	return func(cfg *config) {
+		cfg.clock = c
+	}
+}
@@ -0,0 +1,106 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline/v2/pipelinetest"
+)
+
+// assertNothingReceived fails the test if out has something ready to be received
+func assertNothingReceived[Item any](t *testing.T, out <-chan Item) {
+	t.Helper()
+	select {
+	case i := <-out:
+		t.Errorf("received %v, want nothing", i)
+	default:
+	}
+}
+
+// assertTimerPending fails the test unless a stage is still waiting for the timer of the clock to fire,
+// so it can't have passed on what it is waiting to pass on
+func assertTimerPending(t *testing.T, clock *pipelinetest.FakeClock) {
+	t.Helper()
+	if n := clock.Waiting(); n != 1 {
+		t.Errorf("%d timers are waiting to fire, want 1", n)
+	}
+}
+
+func TestDelayWithClock(t *testing.T) {
+	t.Parallel()
+
+	clock := pipelinetest.NewFakeClock(time.Now())
+	out := Delay(context.Background(), time.Minute, Emit(1, 2, 3), WithClock(clock))
+	for _, want := range []int{1, 2, 3} {
+		if got := pipelinetest.CollectN(t, out, 1, time.Second); got[0] != want {
+			t.Errorf("got %d, want %d", got[0], want)
+		}
+		// The next item is only read once the delay has passed
+		clock.BlockUntil(1)
+		clock.Advance(time.Minute - time.Nanosecond)
+		assertTimerPending(t, clock)
+		clock.Advance(time.Nanosecond)
+	}
+	pipelinetest.Collect(t, out, time.Second)
+}
+
+func TestCollectWithClock(t *testing.T) {
+	t.Parallel()
+
+	clock := pipelinetest.NewFakeClock(time.Now())
+	in := make(chan int)
+	defer close(in)
+	out := Collect(context.Background(), 10, time.Minute, in, WithClock(clock))
+	in <- 1
+	in <- 2
+
+	// The batch is flushed once maxDuration has passed
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute - time.Nanosecond)
+	assertTimerPending(t, clock)
+	clock.Advance(time.Nanosecond)
+	if got := pipelinetest.CollectN(t, out, 1, time.Second); !reflect.DeepEqual(got[0], []int{1, 2}) {
+		t.Errorf("got %v, want [1 2]", got[0])
+	}
+}
+
+func TestProcessBatchWithClock(t *testing.T) {
+	t.Parallel()
+
+	clock := pipelinetest.NewFakeClock(time.Now())
+	in := make(chan int)
+	processor := pipelinetest.Identity[[]int]()
+	out := ProcessBatch[int, int](context.Background(), 10, time.Minute, processor, in, WithClock(clock))
+	in <- 1
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	if got := pipelinetest.CollectN(t, out, 1, time.Second); got[0] != 1 {
+		t.Errorf("got %v, want 1", got)
+	}
+	close(in)
+	pipelinetest.Collect(t, out, time.Second)
+	if got := processor.Processed(); !reflect.DeepEqual(got, [][]int{{1}}) {
+		t.Errorf("processed %v, want [[1]]", got)
+	}
+}
+
+func TestDebounceWithClock(t *testing.T) {
+	t.Parallel()
+
+	clock := pipelinetest.NewFakeClock(time.Now())
+	in := make(chan int)
+	defer close(in)
+	out := Debounce(context.Background(), time.Second, func(int) bool { return true }, in, WithClock(clock))
+	in <- 1
+
+	// The item is only passed on once no other item was received for the duration
+	clock.BlockUntil(1)
+	clock.Advance(time.Second - time.Nanosecond)
+	assertTimerPending(t, clock)
+	clock.Advance(time.Nanosecond)
+	if got := pipelinetest.CollectN(t, out, 1, time.Second); got[0] != 1 {
+		t.Errorf("got %d, want 1", got[0])
+	}
+}
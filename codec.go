@@ -0,0 +1,29 @@
+package pipeline
+
+import "encoding/json"
+
+// Codec encodes items to bytes and decodes them back, so they can be kept on disk, like the items spilled by SpillBuffer
+type Codec[Item any] interface {
+	// Encode encodes an item.
+	Encode(i Item) ([]byte, error)
+
+	// Decode decodes an item encoded by Encode.
+	Decode(data []byte) (Item, error)
+}
+
+// JSONCodec creates a Codec that encodes items as JSON
+func JSONCodec[Item any]() Codec[Item] {
+	return jsonCodec[Item]{}
+}
+
+type jsonCodec[Item any] struct{}
+
+func (jsonCodec[Item]) Encode(i Item) ([]byte, error) {
+	return json.Marshal(i)
+}
+
+func (jsonCodec[Item]) Decode(data []byte) (Item, error) {
+	var i Item
+	err := json.Unmarshal(data, &i)
+	return i, err
+}
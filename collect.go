@@ -10,41 +10,71 @@ import (
 // That means when `maxSize` is reached before `maxDuration`, `[maxSize]Item` will be passed to the out channel.
 // But if `maxDuration` is reached before `maxSize` inputs are collected, `[< maxSize]Item` will be passed to the out channel.
 // When the `context` is canceled, everything in the buffer will be flushed to the out channel.
-func Collect[Item any](ctx context.Context, maxSize int, maxDuration time.Duration, in <-chan Item) <-chan []Item {
-	out := make(chan []Item)
-	go func() {
+func Collect[Item any](ctx context.Context, maxSize int, maxDuration time.Duration, in <-chan Item, opts ...Option) <-chan []Item {
+	return collectBatches(ctx, newConfig("Collect", opts), maxSize, maxDuration, in, func(is []Item) []Item {
+		return is
+	})
+}
+
+// collectBatches runs a stage that collects inputs like Collect, and passes each batch on as what `batch` makes of it
+func collectBatches[Item, Batch any](ctx context.Context, c *config, maxSize int, maxDuration time.Duration, in <-chan Item, batch func([]Item) Batch) <-chan Batch {
+	out := make(chan Batch)
+	connect(c, out, in)
+	goStage(ctx, c.name, c.kind, func() {
 		for {
-			is, open := collect[Item](ctx, maxSize, maxDuration, in)
+			is, first, open := collect[Item](ctx, c, maxSize, maxDuration, in)
 			if is != nil {
-				out <- is
+				c.metrics.Batch(c.name, len(is))
+				c.metrics.QueueWait(c.name, c.clock.Now().Sub(first))
+				deliver(c.node, out, batch(is))
+				c.node.hold(-int64(len(is)))
+				c.metrics.Out(c.name)
 			}
 			if !open {
 				close(out)
+				c.closed()
 				return
 			}
 		}
-	}()
+	})
 	return out
 }
 
-func collect[Item any](ctx context.Context, maxSize int, maxDuration time.Duration, in <-chan Item) ([]Item, bool) {
+// collect returns the inputs it collected, when the first of them was received and whether the in chan is still open
+func collect[Item any](ctx context.Context, c *config, maxSize int, maxDuration time.Duration, in <-chan Item) ([]Item, time.Time, bool) {
 	var buffer []Item
-	timeout := time.After(maxDuration)
+	var first time.Time
+	timeout := c.clock.NewTimer(maxDuration)
+	defer timeout.Stop()
 	for {
 		lenBuffer := len(buffer)
+		c.node.blockedOnReceive(1)
 		select {
 		case <-ctx.Done():
+			c.node.blockedOnReceive(-1)
 			// Reduce the timeout to 1/10th of a second
-			bs, open := collect(context.Background(), maxSize-lenBuffer, 100*time.Millisecond, in)
-			return append(buffer, bs...), open
-		case <-timeout:
-			return buffer, true
+			bs, bsFirst, open := collect(context.Background(), c, maxSize-lenBuffer, 100*time.Millisecond, in)
+			if first.IsZero() {
+				first = bsFirst
+			}
+			return append(buffer, bs...), first, open
+		case <-timeout.C():
+			c.node.blockedOnReceive(-1)
+			return buffer, first, true
 		case i, open := <-in:
+			c.node.blockedOnReceive(-1)
 			if !open {
-				return buffer, false
-			} else if lenBuffer == maxSize-1 {
+				return buffer, first, false
+			}
+			c.metrics.In(c.name)
+			// The caller releases the items once they are passed on
+			c.node.hold(1)
+			if lenBuffer == 0 {
+				first = c.clock.Now()
+			}
+			if lenBuffer == maxSize-1 {
 				// There is no room left in the buffer
-				return append(buffer, i), true
+				return append(buffer, i), first, true
 			}
 			// There is still room in the buffer
 			buffer = append(buffer, i)
@@ -3,8 +3,11 @@ package pipeline
 import (
 	"context"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/deliveryhero/pipeline/v2/pipelinetest"
 )
 
 // TestCollect tests the following cases of the Collect func
@@ -17,13 +20,16 @@ import (
 func TestCollect(t *testing.T) {
 	t.Parallel()
 
-	const maxTestDuration = time.Second
+	const maxDuration = time.Minute
 	type args struct {
-		maxSize     int
-		maxDuration time.Duration
-		in          []int
-		inDelay     time.Duration
-		ctxTimeout  time.Duration
+		maxSize int
+		in      []int
+		// inDelay is how far the clock is advanced before each input is sent
+		inDelay time.Duration
+		// closeIn closes the in channel after the inputs are sent
+		closeIn bool
+		// cancel cancels the context before the inputs are sent
+		cancel bool
 	}
 	type want struct {
 		out  [][]int
@@ -36,11 +42,9 @@ func TestCollect(t *testing.T) {
 	}{{
 		name: "out closes when in closes",
 		args: args{
-			maxSize:     20,
-			maxDuration: maxTestDuration,
-			in:          nil,
-			inDelay:     0,
-			ctxTimeout:  maxTestDuration,
+			maxSize: 20,
+			in:      nil,
+			closeIn: true,
 		},
 		want: want{
 			out:  nil,
@@ -49,11 +53,9 @@ func TestCollect(t *testing.T) {
 	}, {
 		name: "out remains open if in remains open",
 		args: args{
-			maxSize:     2,
-			maxDuration: maxTestDuration,
-			in:          []int{1, 2, 3},
-			inDelay:     (maxTestDuration / 2) - (10 * time.Millisecond),
-			ctxTimeout:  maxTestDuration,
+			maxSize: 2,
+			in:      []int{1, 2, 3},
+			inDelay: maxDuration/2 - time.Second,
 		},
 		want: want{
 			out:  [][]int{{1, 2}},
@@ -62,11 +64,10 @@ func TestCollect(t *testing.T) {
 	}, {
 		name: "collects maxSize inputs and returns",
 		args: args{
-			maxSize:     2,
-			maxDuration: maxTestDuration / 10 * 9,
-			inDelay:     maxTestDuration / 10,
-			in:          []int{1, 2, 3, 4, 5},
-			ctxTimeout:  maxTestDuration / 10 * 9,
+			maxSize: 2,
+			in:      []int{1, 2, 3, 4, 5},
+			inDelay: maxDuration / 10,
+			closeIn: true,
 		},
 		want: want{
 			out: [][]int{
@@ -79,11 +80,9 @@ func TestCollect(t *testing.T) {
 	}, {
 		name: "collection returns after maxDuration with < maxSize",
 		args: args{
-			maxSize:     10,
-			maxDuration: maxTestDuration / 4,
-			inDelay:     (maxTestDuration / 4) - (25 * time.Millisecond),
-			in:          []int{1, 2, 3, 4, 5},
-			ctxTimeout:  maxTestDuration / 4,
+			maxSize: 10,
+			in:      []int{1, 2, 3, 4, 5},
+			inDelay: maxDuration,
 		},
 		want: want{
 			out: [][]int{
@@ -97,11 +96,10 @@ func TestCollect(t *testing.T) {
 	}, {
 		name: "collection flushes buffer when the context is canceled",
 		args: args{
-			maxSize:     10,
-			maxDuration: maxTestDuration,
-			inDelay:     0,
-			in:          []int{1, 2, 3, 4, 5},
-			ctxTimeout:  0,
+			maxSize: 10,
+			in:      []int{1, 2, 3, 4, 5},
+			closeIn: true,
+			cancel:  true,
 		},
 		want: want{
 			out: [][]int{
@@ -110,40 +108,64 @@ func TestCollect(t *testing.T) {
 			open: false,
 		},
 	}} {
+		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
 
-			// Create the in channel
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if test.args.cancel {
+				cancel()
+			}
+			clock := pipelinetest.NewFakeClock(time.Now())
+			g := NewGraph()
 			in := make(chan int)
+			collect := Collect(ctx, test.args.maxSize, maxDuration, in, WithClock(clock), WithGraph(g))
+
+			// Receive the batches while the inputs are sent
+			var mu sync.Mutex
+			var outs [][]int
+			closed := make(chan struct{})
 			go func() {
-				defer close(in)
-				for _, i := range test.args.in {
-					time.Sleep(test.args.inDelay)
-					in <- i
+				defer close(closed)
+				for out := range collect {
+					mu.Lock()
+					outs = append(outs, out)
+					mu.Unlock()
 				}
 			}()
 
-			// Create the context
-			ctx, cancel := context.WithTimeout(context.Background(), test.args.ctxTimeout)
-			defer cancel()
+			for _, i := range test.args.in {
+				if test.args.inDelay > 0 {
+					clock.BlockUntil(1)
+					clock.Advance(test.args.inDelay)
+					// Wait for Collect to pass on what it collected, if the clock reached maxDuration, before it can receive the input
+					clock.BlockUntil(1)
+				}
+				in <- i
+			}
 
-			// Collect responses
-			collect := Collect(ctx, test.args.maxSize, test.args.maxDuration, in)
-			timeout := time.After(maxTestDuration)
-			var outs [][]int
 			var isOpen bool
-		loop:
-			for {
+			if test.args.closeIn {
+				close(in)
 				select {
-				case out, open := <-collect:
-					if !open {
-						isOpen = false
-						break loop
-					}
+				case <-closed:
+				case <-time.After(time.Second):
+					t.Fatal("out was not closed")
+				}
+			} else {
+				defer close(in)
+				// Collect is waiting for the next input once it passed on every batch
+				clock.BlockUntil(1)
+				waitFor(t, func() bool {
+					mu.Lock()
+					defer mu.Unlock()
+					return int64(len(outs)) == g.Status()[0].Out
+				})
+				select {
+				case <-closed:
+				default:
 					isOpen = true
-					outs = append(outs, out)
-				case <-timeout:
-					break loop
 				}
 			}
 
@@ -153,6 +175,8 @@ func TestCollect(t *testing.T) {
 			}
 
 			// Expecting outputs
+			mu.Lock()
+			defer mu.Unlock()
 			if !reflect.DeepEqual(test.want.out, outs) {
 				t.Errorf("out = %v, want %v", outs, test.want.out)
 			}
@@ -0,0 +1,73 @@
+package pipeline
+
+import "context"
+
+// CombineLatest passes a `Pair[A, B]` of the latest items from `a <-chan A` and `b <-chan B` to the out channel
+// every time either input produces an item, once both inputs have produced at least one item.
+// The out channel is closed after both inputs are closed or the `Context` is canceled.
+// If the context is canceled, anything remaining in the inputs is drained, so the upstream stages never block.
+func CombineLatest[A, B any](ctx context.Context, a <-chan A, b <-chan B, opts ...Option) <-chan Pair[A, B] {
+	c := newConfig("CombineLatest", opts)
+	out := make(chan Pair[A, B])
+	connect(c, out, a, b)
+	goStage(ctx, c.name, c.kind, func() {
+		defer c.closed()
+		defer close(out)
+		var latest Pair[A, B]
+		var hasA, hasB bool
+		// The item of one input is held until the other input produces one
+		var held int64
+		defer func() { c.node.hold(-held) }()
+		// A nil chan blocks forever, so closed inputs are set to nil
+		ac, bc := a, b
+		for ac != nil || bc != nil {
+			c.node.blockedOnReceive(1)
+			select {
+			case <-ctx.Done():
+				c.node.blockedOnReceive(-1)
+				drainOpen(ac)
+				drainOpen(bc)
+				return
+			case i, open := <-ac:
+				c.node.blockedOnReceive(-1)
+				if !open {
+					ac = nil
+					continue
+				}
+				c.metrics.In(c.name)
+				latest.First, hasA = i, true
+			case i, open := <-bc:
+				c.node.blockedOnReceive(-1)
+				if !open {
+					bc = nil
+					continue
+				}
+				c.metrics.In(c.name)
+				latest.Second, hasB = i, true
+			}
+			if !hasA || !hasB {
+				if held == 0 {
+					c.node.hold(1)
+					held = 1
+				}
+				continue
+			}
+			c.node.hold(-held)
+			held = 0
+			if !send(ctx, c.node, latest, out) {
+				drainOpen(ac)
+				drainOpen(bc)
+				return
+			}
+			c.metrics.Out(c.name)
+		}
+	})
+	return out
+}
+
+// drainOpen drains in in the background, unless it is nil
+func drainOpen[Item any](in <-chan Item) {
+	if in != nil {
+		go Drain(in)
+	}
+}
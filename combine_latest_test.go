@@ -0,0 +1,151 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCombineLatest(t *testing.T) {
+	t.Parallel()
+
+	const maxTestDuration = time.Second
+	// send describes one item sent to either a or b
+	type send struct {
+		a *int
+		b *string
+	}
+	intp := func(i int) *int { return &i }
+	strp := func(s string) *string { return &s }
+	type args struct {
+		sends      []send
+		closeB     bool
+		ctxTimeout time.Duration
+	}
+	type want struct {
+		out  []Pair[int, string]
+		open bool
+	}
+	for _, test := range []struct {
+		name string
+		args args
+		want want
+	}{{
+		name: "emits the latest pair whenever either input produces",
+		args: args{
+			sends: []send{
+				{a: intp(1)},
+				{a: intp(2)},
+				{b: strp("a")},
+				{a: intp(3)},
+				{b: strp("b")},
+				{b: strp("c")},
+			},
+			closeB:     true,
+			ctxTimeout: maxTestDuration,
+		},
+		want: want{
+			out:  []Pair[int, string]{{2, "a"}, {3, "a"}, {3, "b"}, {3, "c"}},
+			open: false,
+		},
+	}, {
+		name: "out stays open as long as either input is open",
+		args: args{
+			sends: []send{
+				{a: intp(1)},
+				{b: strp("a")},
+			},
+			closeB:     false,
+			ctxTimeout: 2 * maxTestDuration,
+		},
+		want: want{
+			out:  []Pair[int, string]{{1, "a"}},
+			open: true,
+		},
+	}, {
+		name: "out closes when the context is canceled",
+		args: args{
+			sends: []send{
+				{a: intp(1)},
+				{b: strp("a")},
+			},
+			closeB:     false,
+			ctxTimeout: maxTestDuration / 2,
+		},
+		want: want{
+			out:  []Pair[int, string]{{1, "a"}},
+			open: false,
+		},
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithTimeout(context.Background(), test.args.ctxTimeout)
+			defer cancel()
+
+			// Send to a and b in the order of the test
+			a, b := make(chan int), make(chan string)
+			go func() {
+				defer close(a)
+				for _, s := range test.args.sends {
+					if s.a != nil {
+						a <- *s.a
+					} else {
+						b <- *s.b
+					}
+				}
+				if test.args.closeB {
+					close(b)
+				}
+			}()
+
+			combined := CombineLatest(ctx, a, b)
+			timeout := time.After(maxTestDuration)
+			var outs []Pair[int, string]
+			var isOpen bool
+		loop:
+			for {
+				select {
+				case o, open := <-combined:
+					isOpen = open
+					if !open {
+						break loop
+					}
+					outs = append(outs, o)
+				case <-timeout:
+					break loop
+				}
+			}
+
+			if test.want.open != isOpen {
+				t.Errorf("open = %t, want %t", isOpen, test.want.open)
+			}
+			if !reflect.DeepEqual(test.want.out, outs) {
+				t.Errorf("out = %+v, want %+v", outs, test.want.out)
+			}
+		})
+	}
+}
+
+func TestCombineLatestHeld(t *testing.T) {
+	t.Parallel()
+
+	g := NewGraph()
+	a, b := make(chan int), make(chan string)
+	out := CombineLatest[int, string](context.Background(), a, b, WithGraph(g))
+
+	// The items of one input are held until the other input produces one
+	a <- 1
+	a <- 2
+	waitFor(t, func() bool { return g.Status()[0].Held == 1 })
+	b <- "a"
+	if got := <-out; got != (Pair[int, string]{2, "a"}) {
+		t.Errorf("got %v, want {2 a}", got)
+	}
+	waitFor(t, func() bool { return g.Status()[0].Held == 0 })
+	close(a)
+	close(b)
+	Drain(out)
+}
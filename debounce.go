@@ -0,0 +1,87 @@
+package pipeline
+
+import (
+	"container/list"
+	"context"
+	"time"
+)
+
+// Debounce passes only the latest item of each key from `in <-chan Item` to the out channel,
+// once no other item with the same key has been received for `duration`.
+// Items are keyed by `keyFn`, so each key is debounced independently. Use a `keyFn` that returns a constant to debounce all items together.
+// When the in channel is closed, the latest item of every key is passed to the out channel before it is closed.
+// If the context is canceled, the latest items are passed to the out channel and the debounce is no longer applied.
+func Debounce[Item any, Key comparable](ctx context.Context, duration time.Duration, keyFn func(Item) Key, in <-chan Item, opts ...Option) <-chan Item {
+	c := newConfig("Debounce", opts)
+	out := make(chan Item)
+	connect(c, out, in)
+	goStage(ctx, c.name, c.kind, func() {
+		defer c.closed()
+		defer close(out)
+		// pending holds the latest item of each key, ordered from the oldest to the newest
+		pending := list.New()
+		index := make(map[Key]*list.Element)
+		type debounced struct {
+			key  Key
+			item Item
+			at   time.Time
+		}
+		// flush passes the pending items to out, until one is found that was received after before
+		flush := func(before time.Time) {
+			for el := pending.Front(); el != nil; el = pending.Front() {
+				d := el.Value.(*debounced)
+				if d.at.After(before) {
+					return
+				}
+				pending.Remove(el)
+				delete(index, d.key)
+				deliver(c.node, out, d.item)
+				c.node.hold(-1)
+				c.metrics.Out(c.name)
+			}
+		}
+
+		fire := newAlarm(c.clock)
+		defer fire.stop()
+	loop:
+		for {
+			select {
+			case <-ctx.Done():
+				break loop
+			case now := <-fire.C():
+				fire.fired()
+				flush(now.Add(-duration))
+			case i, open := <-in:
+				if !open {
+					flush(c.clock.Now())
+					return
+				}
+				c.metrics.In(c.name)
+				key := keyFn(i)
+				if el, ok := index[key]; ok {
+					pending.Remove(el)
+				} else {
+					c.node.hold(1)
+				}
+				index[key] = pending.PushBack(&debounced{key, i, c.clock.Now()})
+			}
+			// Make sure the alarm fires when the oldest item is due
+			if el := pending.Front(); el != nil {
+				fire.set(el.Value.(*debounced).at.Add(duration))
+			}
+		}
+
+		// The context is canceled, so stop debouncing
+		flush(c.clock.Now())
+		for {
+			i, open := receive(c.node, in)
+			if !open {
+				return
+			}
+			c.metrics.In(c.name)
+			deliver(c.node, out, i)
+			c.metrics.Out(c.name)
+		}
+	})
+	return out
+}
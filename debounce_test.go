@@ -0,0 +1,114 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// ping is a location ping sent by a courier
+type ping struct {
+	courier string
+	seq     int
+}
+
+// pingStep sends a ping after waiting
+type pingStep struct {
+	wait time.Duration
+	ping ping
+}
+
+// sendPings sends the pings to the returned chan, closing it after the last one
+func sendPings(steps []pingStep) <-chan ping {
+	out := make(chan ping)
+	go func() {
+		defer close(out)
+		for _, s := range steps {
+			time.Sleep(s.wait)
+			out <- s.ping
+		}
+	}()
+	return out
+}
+
+func TestDebounce(t *testing.T) {
+	t.Parallel()
+
+	const maxTestDuration = time.Second
+	type args struct {
+		duration   time.Duration
+		ctxTimeout time.Duration
+		steps      []pingStep
+	}
+	type want struct {
+		out []ping
+	}
+	for _, test := range []struct {
+		name string
+		args args
+		want want
+	}{{
+		name: "passes the latest item after no items are received for the duration",
+		args: args{
+			duration:   maxTestDuration / 10,
+			ctxTimeout: maxTestDuration,
+			steps: []pingStep{
+				{ping: ping{"a", 1}},
+				{ping: ping{"a", 2}},
+				{ping: ping{"a", 3}},
+				{wait: maxTestDuration / 5, ping: ping{"a", 4}},
+			},
+		},
+		want: want{
+			out: []ping{{"a", 3}, {"a", 4}},
+		},
+	}, {
+		name: "each key is debounced independently",
+		args: args{
+			duration:   maxTestDuration / 10,
+			ctxTimeout: maxTestDuration,
+			steps: []pingStep{
+				{ping: ping{"a", 1}},
+				{ping: ping{"b", 1}},
+				{ping: ping{"a", 2}},
+				{wait: maxTestDuration / 5, ping: ping{"b", 2}},
+			},
+		},
+		want: want{
+			out: []ping{{"b", 1}, {"a", 2}, {"b", 2}},
+		},
+	}, {
+		name: "debounce is not applied when the context is canceled",
+		args: args{
+			duration:   2 * maxTestDuration,
+			ctxTimeout: maxTestDuration / 10,
+			steps: []pingStep{
+				{ping: ping{"a", 1}},
+				{ping: ping{"a", 2}},
+				{wait: maxTestDuration / 5, ping: ping{"a", 3}},
+				{ping: ping{"a", 4}},
+			},
+		},
+		want: want{
+			out: []ping{{"a", 2}, {"a", 3}, {"a", 4}},
+		},
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithTimeout(context.Background(), test.args.ctxTimeout)
+			defer cancel()
+
+			var outs []ping
+			for p := range Debounce(ctx, test.args.duration, func(p ping) string { return p.courier }, sendPings(test.args.steps)) {
+				outs = append(outs, p)
+			}
+
+			if !reflect.DeepEqual(test.want.out, outs) {
+				t.Errorf("out = %+v, want %+v", outs, test.want.out)
+			}
+		})
+	}
+}
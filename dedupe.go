@@ -0,0 +1,94 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrDuplicate is returned by a Processor created with Dedupe when its input is a duplicate
+var ErrDuplicate = errors.New("duplicate")
+
+type dedupe[Input, Output any, Key comparable] struct {
+	p     Processor[Input, Output]
+	keyFn func(Input) Key
+	store SeenStore[Key]
+	// inFlight holds the keys being processed, which are closed once they are done
+	mu       sync.Mutex
+	inFlight map[Key]chan struct{}
+}
+
+func (d *dedupe[Input, Output, Key]) Process(ctx context.Context, i Input) (Output, error) {
+	var zero Output
+	key := d.keyFn(i)
+	// A duplicate that arrives while the key is processed waits for it, since the key is forgotten if processing fails
+	done, err := d.acquire(ctx, key)
+	if err != nil {
+		return zero, err
+	}
+	defer d.release(key, done)
+	if seen, err := d.store.Seen(key); err != nil {
+		return zero, err
+	} else if seen {
+		return zero, ErrDuplicate
+	}
+	o, err := d.p.Process(ctx, i)
+	if err != nil {
+		// Failed inputs are not duplicates if they are delivered again.
+		// The processing error is more important than an error forgetting the key.
+		_ = d.store.Forget(key)
+	}
+	return o, err
+}
+
+// acquire waits until no other input with the key is processed, then marks the key as processed.
+// It returns the error of the context if it is canceled first.
+func (d *dedupe[Input, Output, Key]) acquire(ctx context.Context, key Key) (chan struct{}, error) {
+	for {
+		d.mu.Lock()
+		other, ok := d.inFlight[key]
+		if !ok {
+			done := make(chan struct{})
+			d.inFlight[key] = done
+			d.mu.Unlock()
+			return done, nil
+		}
+		d.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-other:
+		}
+	}
+}
+
+// release marks the key as no longer processed, waking up the duplicates waiting for it
+func (d *dedupe[Input, Output, Key]) release(key Key, done chan struct{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.inFlight, key)
+	close(done)
+}
+
+func (d *dedupe[Input, Output, Key]) Cancel(i Input, err error) {
+	// Duplicates are dropped silently
+	if errors.Is(err, ErrDuplicate) {
+		return
+	}
+	d.p.Cancel(i, err)
+}
+
+// Dedupe wraps a Processor so that it only processes inputs whose key has not been seen before.
+// It remembers up to 100,000 keys, after which the least recently seen key is forgotten.
+// Duplicates return ErrDuplicate, but they are never passed to the `Processor.Cancel` of the wrapped processor.
+// Process and ProcessConcurrently report them to `Metrics` as dropped, if it implements DropMetrics, rather than as errors.
+// If the wrapped processor returns an error, the key is forgotten, so the input can be processed again if it is redelivered.
+// An input whose key is being processed, e.g. by ProcessConcurrently, waits until it is done, so it is only a duplicate if that succeeded.
+func Dedupe[Input, Output any, Key comparable](p Processor[Input, Output], keyFn func(Input) Key) Processor[Input, Output] {
+	return DedupeStore(p, keyFn, NewSeenStore[Key](0, defaultSeenStoreSize))
+}
+
+// DedupeStore is like Dedupe, except the keys are remembered by `store`, e.g. to forget them after a TTL or keep them on disk
+func DedupeStore[Input, Output any, Key comparable](p Processor[Input, Output], keyFn func(Input) Key, store SeenStore[Key]) Processor[Input, Output] {
+	return &dedupe[Input, Output, Key]{p: p, keyFn: keyFn, store: store, inFlight: make(map[Key]chan struct{})}
+}
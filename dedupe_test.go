@@ -0,0 +1,143 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline/v2/pipelinetest"
+)
+
+func TestDedupe(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		processReturnsErrors bool
+		in                   []int
+	}
+	type want struct {
+		out      []int
+		canceled []int
+	}
+	for _, test := range []struct {
+		name string
+		args args
+		want want
+	}{{
+		name: "duplicates are not processed or canceled",
+		args: args{
+			in: []int{1, 2, 1, 3, 2, 3},
+		},
+		want: want{
+			out:      []int{1, 2, 3},
+			canceled: nil,
+		},
+	}, {
+		name: "inputs that fail are not duplicates",
+		args: args{
+			processReturnsErrors: true,
+			in:                   []int{1, 1, 2},
+		},
+		want: want{
+			out:      nil,
+			canceled: []int{1, 1, 2},
+		},
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			processor := &mockProcessor[int]{
+				processReturnsErrs: test.args.processReturnsErrors,
+			}
+			dedupe := Dedupe[int, int](processor, func(i int) int { return i })
+
+			var outs []int
+			for o := range Process(context.Background(), dedupe, Emit(test.args.in...)) {
+				outs = append(outs, o)
+			}
+
+			if !reflect.DeepEqual(test.want.out, outs) {
+				t.Errorf("out = %+v, want %+v", outs, test.want.out)
+			}
+			if !reflect.DeepEqual(test.want.canceled, processor.canceled) {
+				t.Errorf("canceled = %+v, want %+v", processor.canceled, test.want.canceled)
+			}
+		})
+	}
+}
+
+func TestDedupeStore(t *testing.T) {
+	t.Parallel()
+
+	// The key was seen by another processor sharing the store
+	store := NewSeenStore[int](time.Minute, 10)
+	store.Seen(1)
+	processor := &mockProcessor[int]{}
+	dedupe := DedupeStore[int, int](processor, func(i int) int { return i }, store)
+
+	var outs []int
+	for o := range Process(context.Background(), dedupe, Emit(1, 2)) {
+		outs = append(outs, o)
+	}
+	if !reflect.DeepEqual(outs, []int{2}) {
+		t.Errorf("out = %+v, want [2]", outs)
+	}
+}
+
+func TestDedupeMetrics(t *testing.T) {
+	t.Parallel()
+
+	// Duplicates are dropped, rather than processed or failed
+	m := newMockMetrics()
+	dedupe := Dedupe[int, int](&mockProcessor[int]{}, func(i int) int { return i })
+	Drain(Process(context.Background(), dedupe, Emit(1, 1, 2), WithName("dedupe"), WithMetrics(m)))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.processed["dedupe"] != 2 || m.errs["dedupe"] != 0 || m.canceled["dedupe"] != 0 || m.dropped["dedupe"] != 1 {
+		t.Errorf("processed = %d, errs = %d, canceled = %d, dropped = %d, want 2, 0, 0 and 1",
+			m.processed["dedupe"], m.errs["dedupe"], m.canceled["dedupe"], m.dropped["dedupe"])
+	}
+}
+
+// failFirstProcessor fails the first input it processes once it is released, and returns the others as they are
+type failFirstProcessor struct {
+	release chan struct{}
+	once    sync.Once
+}
+
+func (p *failFirstProcessor) Process(_ context.Context, i int) (int, error) {
+	first := false
+	p.once.Do(func() { first = true })
+	if first {
+		<-p.release
+		return 0, errors.New("failed")
+	}
+	return i, nil
+}
+
+func (p *failFirstProcessor) Cancel(int, error) {}
+
+func TestDedupeRedeliveredWhileProcessing(t *testing.T) {
+	t.Parallel()
+
+	// The input is delivered again while the first delivery is processed, which then fails
+	g := NewGraph()
+	p := &failFirstProcessor{release: make(chan struct{})}
+	in := make(chan int)
+	out := ProcessConcurrently(context.Background(), 2, Dedupe[int, int](p, func(i int) int { return i }), in, WithGraph(g))
+	in <- 1
+	in <- 1
+	waitFor(t, func() bool { return g.Status()[0].Concurrent == 2 })
+	close(p.release)
+	close(in)
+
+	// The second delivery is processed, rather than dropped as a duplicate
+	if outs := pipelinetest.Collect(t, out, time.Second); !reflect.DeepEqual(outs, []int{1}) {
+		t.Errorf("out = %+v, want [1]", outs)
+	}
+}
@@ -7,21 +7,32 @@ import (
 
 // Delay delays reading each input by `duration`.
 // If the context is canceled, the delay will not be applied.
-func Delay[Item any](ctx context.Context, duration time.Duration, in <-chan Item) <-chan Item {
+func Delay[Item any](ctx context.Context, duration time.Duration, in <-chan Item, opts ...Option) <-chan Item {
+	c := newConfig("Delay", opts)
 	out := make(chan Item)
-	go func() {
+	connect(c, out, in)
+	goStage(ctx, c.name, c.kind, func() {
+		defer c.closed()
 		defer close(out)
 		// Keep reading from in until its closed
-		for i := range in {
+		for {
+			i, open := receive(c.node, in)
+			if !open {
+				return
+			}
 			// Take one element from in and pass it to out
-			out <- i
+			c.metrics.In(c.name)
+			deliver(c.node, out, i)
+			c.metrics.Out(c.name)
+			wait := c.clock.NewTimer(duration)
 			select {
 			// Wait duration before reading another input
-			case <-time.After(duration):
+			case <-wait.C():
 			// Don't wait if the context is canceled
 			case <-ctx.Done():
+				wait.Stop()
 			}
 		}
-	}()
+	})
 	return out
 }
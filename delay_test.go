@@ -5,16 +5,20 @@ import (
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/deliveryhero/pipeline/v2/pipelinetest"
 )
 
 func TestDelay(t *testing.T) {
 	t.Parallel()
 
-	const maxTestDuration = time.Second
+	const duration = time.Minute
 	type args struct {
-		ctxTimeout time.Duration
-		duration   time.Duration
-		in         []int
+		// delays is how many times the clock is advanced by duration
+		delays int
+		// cancel cancels the context before anything is read
+		cancel bool
+		in     []int
 	}
 	type want struct {
 		out  []int
@@ -27,9 +31,8 @@ func TestDelay(t *testing.T) {
 	}{{
 		name: "out closes after duration when in closes",
 		args: args{
-			ctxTimeout: maxTestDuration,
-			duration:   maxTestDuration - 100*time.Millisecond,
-			in:         []int{1},
+			delays: 1,
+			in:     []int{1},
 		},
 		want: want{
 			out:  []int{1},
@@ -38,9 +41,8 @@ func TestDelay(t *testing.T) {
 	}, {
 		name: "delay is not applied when the context is canceled",
 		args: args{
-			ctxTimeout: 10 * time.Millisecond,
-			duration:   maxTestDuration,
-			in:         []int{1, 2, 3, 4, 5},
+			cancel: true,
+			in:     []int{1, 2, 3, 4, 5},
 		},
 		want: want{
 			out:  []int{1, 2, 3, 4, 5},
@@ -49,42 +51,53 @@ func TestDelay(t *testing.T) {
 	}, {
 		name: "out is delayed by duration",
 		args: args{
-			ctxTimeout: maxTestDuration,
-			duration:   maxTestDuration / 4,
-			in:         []int{1, 2, 3, 4, 5},
+			delays: 3,
+			in:     []int{1, 2, 3, 4, 5},
 		},
 		want: want{
 			out:  []int{1, 2, 3, 4},
 			open: true,
 		},
 	}} {
+		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
 
-			// Create in channel
-			in := Emit(test.args.in...)
-
-			// Create a context with a timeut
-			ctx, cancel := context.WithTimeout(context.Background(), test.args.ctxTimeout)
-			defer cancel()
+			ctx, cancel := context.WithCancel(context.Background())
+			if test.args.cancel {
+				cancel()
+			}
+			clock := pipelinetest.NewFakeClock(time.Now())
+			delay := Delay(ctx, duration, Emit(test.args.in...), WithClock(clock))
+			defer func() {
+				cancel()
+				Drain(delay)
+			}()
 
-			// Start reading from in
-			delay := Delay(ctx, test.args.duration, in)
-			timeout := time.After(maxTestDuration)
 			var isOpen bool
 			var outs []int
-		loop:
-			for {
+			for delays := 0; ; delays++ {
 				select {
 				case i, open := <-delay:
 					isOpen = open
-					if !open {
-						break loop
+					if open {
+						outs = append(outs, i)
 					}
-					outs = append(outs, i)
-				case <-timeout:
-					break loop
+				case <-time.After(time.Second):
+					t.Fatal("nothing was received")
+				}
+				if !isOpen {
+					break
+				}
+				if test.args.cancel {
+					continue
+				}
+				// Delay waits for duration after passing on each item
+				clock.BlockUntil(1)
+				if delays == test.args.delays {
+					break
 				}
+				clock.Advance(duration)
 			}
 
 			// Expecting the out channel to be open or closed
@@ -0,0 +1,55 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+)
+
+// defaultSeenStoreSize is the number of keys remembered by Distinct
+const defaultSeenStoreSize = 100000
+
+// Distinct passes the items from `in <-chan Item` to the out channel, dropping every item whose key was already seen within `ttl`.
+// The ttl is measured with the clock set WithClock.
+// Every duplicate counts as seeing the key again, so a key is only forgotten once it has not been seen for `ttl`.
+// It remembers up to 100,000 keys, after which the least recently seen key is forgotten.
+// The out channel is closed after the in channel is closed or the `Context` is canceled.
+// If the context is canceled, anything remaining in the in channel is drained, so the upstream stages never block.
+func Distinct[Item any, Key comparable](ctx context.Context, keyFn func(Item) Key, ttl time.Duration, in <-chan Item, opts ...Option) <-chan Item {
+	c := newConfig("Distinct", opts)
+	return distinct[Item, Key](ctx, c, keyFn, newSeenStore[Key](c.clock, ttl, defaultSeenStoreSize), in)
+}
+
+// DistinctStore is like Distinct, except the keys are remembered by `store`.
+// If the store returns an error, the item is passed to the out channel, since it can't be known to be a duplicate.
+func DistinctStore[Item any, Key comparable](ctx context.Context, keyFn func(Item) Key, store SeenStore[Key], in <-chan Item, opts ...Option) <-chan Item {
+	c := newConfig("Distinct", opts)
+	return distinct(ctx, c, keyFn, store, in)
+}
+
+func distinct[Item any, Key comparable](ctx context.Context, c *config, keyFn func(Item) Key, store SeenStore[Key], in <-chan Item) <-chan Item {
+	out := make(chan Item)
+	connect(c, out, in)
+	goStage(ctx, c.name, c.kind, func() {
+		defer c.closed()
+		defer close(out)
+		for {
+			i, open, ok := receiveOrDone(ctx, c.node, in)
+			if !ok {
+				go Drain(in)
+				return
+			} else if !open {
+				return
+			}
+			c.metrics.In(c.name)
+			if seen, err := store.Seen(keyFn(i)); err == nil && seen {
+				continue
+			}
+			if !send(ctx, c.node, i, out) {
+				go Drain(in)
+				return
+			}
+			c.metrics.Out(c.name)
+		}
+	})
+	return out
+}
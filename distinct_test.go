@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDistinct(t *testing.T) {
+	t.Parallel()
+
+	const maxTestDuration = time.Second
+	type event struct {
+		id      int
+		attempt int
+	}
+	type args struct {
+		in         []event
+		inDelay    time.Duration
+		ttl        time.Duration
+		ctxTimeout time.Duration
+	}
+	type want struct {
+		out []event
+	}
+	for _, test := range []struct {
+		name string
+		args args
+		want want
+	}{{
+		name: "drops items with keys seen within the ttl",
+		args: args{
+			in:         []event{{1, 1}, {2, 1}, {1, 2}, {3, 1}, {2, 2}},
+			ttl:        maxTestDuration,
+			ctxTimeout: maxTestDuration,
+		},
+		want: want{
+			out: []event{{1, 1}, {2, 1}, {3, 1}},
+		},
+	}, {
+		name: "passes items with keys seen before the ttl",
+		args: args{
+			in:         []event{{1, 1}, {1, 2}, {1, 3}},
+			inDelay:    maxTestDuration / 5,
+			ttl:        maxTestDuration / 10,
+			ctxTimeout: maxTestDuration,
+		},
+		want: want{
+			out: []event{{1, 1}, {1, 2}, {1, 3}},
+		},
+	}, {
+		name: "out closes when the context is canceled",
+		args: args{
+			in:         []event{{1, 1}, {2, 1}, {3, 1}},
+			inDelay:    maxTestDuration / 4,
+			ttl:        maxTestDuration,
+			ctxTimeout: maxTestDuration / 3,
+		},
+		want: want{
+			out: []event{{1, 1}},
+		},
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithTimeout(context.Background(), test.args.ctxTimeout)
+			defer cancel()
+
+			in := make(chan event)
+			go func() {
+				defer close(in)
+				for _, e := range test.args.in {
+					time.Sleep(test.args.inDelay)
+					in <- e
+				}
+			}()
+
+			var outs []event
+			for o := range Distinct(ctx, func(e event) int { return e.id }, test.args.ttl, in) {
+				outs = append(outs, o)
+			}
+
+			if !reflect.DeepEqual(test.want.out, outs) {
+				t.Errorf("out = %+v, want %+v", outs, test.want.out)
+			}
+		})
+	}
+}
+
+func TestDistinctCanceledWhileWaiting(t *testing.T) {
+	t.Parallel()
+
+	// Nothing is ever sent to the in channel
+	ctx, cancel := context.WithCancel(context.Background())
+	out := Distinct(ctx, func(i int) int { return i }, time.Minute, make(chan int))
+	cancel()
+	select {
+	case _, open := <-out:
+		if open {
+			t.Error("out received an item")
+		}
+	case <-time.After(time.Second):
+		t.Error("out was not closed")
+	}
+}
@@ -5,29 +5,33 @@ import "context"
 // Emit fans `is ...Item`` out to a `<-chan Item`
 func Emit[Item any](is ...Item) <-chan Item {
 	out := make(chan Item)
-	go func() {
+	goStage(context.Background(), "Emit", "Emit", func() {
 		defer close(out)
 		for _, i := range is {
 			out <- i
 		}
-	}()
+	})
 	return out
 }
 
 // Emitter continuously emits new items generated by the next func
 // until the context is canceled
-func Emitter[Item any](ctx context.Context, next func() Item) <-chan Item {
+func Emitter[Item any](ctx context.Context, next func() Item, opts ...Option) <-chan Item {
+	c := newConfig("Emitter", opts)
 	out := make(chan Item)
-	go func() {
+	connect(c, out)
+	goStage(ctx, c.name, c.kind, func() {
+		defer c.closed()
 		defer close(out)
 		for {
 			select {
 			case <-ctx.Done():
 				return
 			default:
-				out <- next()
+				deliver(c.node, out, next())
+				c.metrics.Out(c.name)
 			}
 		}
-	}()
+	})
 	return out
 }
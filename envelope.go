@@ -0,0 +1,185 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Envelope carries a value through a pipeline along with the means to acknowledge it,
+// so a source like a queue consumer can tell when the value was fully processed by every stage.
+// Every envelope must eventually be acked or nacked exactly once; only the first call has any effect.
+//
+// Envelopes are passed through Processors with WithAck, through Apply with ApplyEnvelopes,
+// through Collect with CollectEnvelopes and through Split with SplitEnvelopes.
+// Stages that drop items, like Distinct or Take, don't ack the envelopes they drop.
+type Envelope[T any] struct {
+	Value T
+	once  *sync.Once
+	ack   func(err error)
+}
+
+// NewEnvelope puts a value in an envelope. `ack` is called once, with nil when the envelope is acked or the error it is nacked with.
+func NewEnvelope[T any](value T, ack func(err error)) Envelope[T] {
+	return Envelope[T]{Value: value, once: new(sync.Once), ack: ack}
+}
+
+// Ack acknowledges that the value was fully processed
+func (e Envelope[T]) Ack() {
+	e.done(nil)
+}
+
+// Nack reports that the value could not be processed because of err
+func (e Envelope[T]) Nack(err error) {
+	e.done(err)
+}
+
+func (e Envelope[T]) done(err error) {
+	if e.once != nil {
+		e.once.Do(func() {
+			e.ack(err)
+		})
+	}
+}
+
+// readdress puts another value in the same envelope, so acking either acks both
+func readdress[T, U any](e Envelope[T], value U) Envelope[U] {
+	return Envelope[U]{Value: value, once: e.once, ack: e.ack}
+}
+
+// WithAck wraps a Processor so that it processes the values of envelopes.
+// The output is sent in the same envelope as the input, so the input is acked when the output is.
+// Inputs that are canceled are nacked with the error they are canceled with, after `Processor.Cancel` is called.
+func WithAck[Input, Output any](p Processor[Input, Output]) Processor[Envelope[Input], Envelope[Output]] {
+	return &ackProcessor[Input, Output]{p}
+}
+
+type ackProcessor[Input, Output any] struct {
+	processor Processor[Input, Output]
+}
+
+func (p *ackProcessor[Input, Output]) Process(ctx context.Context, e Envelope[Input]) (Envelope[Output], error) {
+	o, err := p.processor.Process(ctx, e.Value)
+	return readdress(e, o), err
+}
+
+func (p *ackProcessor[Input, Output]) Cancel(e Envelope[Input], err error) {
+	p.processor.Cancel(e.Value, err)
+	e.Nack(err)
+}
+
+// ApplyEnvelopes connects two processes like Apply, for the values of envelopes.
+// Each output is put in an envelope of its own, so the outputs can be split and acked one by one.
+// The input is acked once all of its outputs are acked, or nacked with the first error once they are all acked or nacked.
+// It is acked straight away if there are no outputs, and nacked with the error if either process fails.
+func ApplyEnvelopes[A, B, C any](a Processor[A, []B], b Processor[B, C]) Processor[Envelope[A], []Envelope[C]] {
+	return &applyEnvelopes[A, B, C]{apply[A, B, C]{a, b}}
+}
+
+type applyEnvelopes[A, B, C any] struct {
+	apply apply[A, B, C]
+}
+
+func (p *applyEnvelopes[A, B, C]) Process(ctx context.Context, e Envelope[A]) ([]Envelope[C], error) {
+	cs, err := p.apply.Process(ctx, e.Value)
+	if err != nil {
+		return nil, err
+	}
+	if len(cs) == 0 {
+		e.Ack()
+		return nil, nil
+	}
+	a := newAcker(len(cs), e.done)
+	es := make([]Envelope[C], len(cs))
+	for i, c := range cs {
+		es[i] = NewEnvelope(c, a.ack)
+	}
+	return es, nil
+}
+
+// Cancel nacks the input with the error it was canceled with
+func (p *applyEnvelopes[A, B, C]) Cancel(e Envelope[A], err error) {
+	e.Nack(err)
+}
+
+// CollectEnvelopes collects envelopes like Collect, and passes each batch on in a single envelope.
+// Acking or nacking the batch acks or nacks every envelope in it.
+func CollectEnvelopes[T any](ctx context.Context, maxSize int, maxDuration time.Duration, in <-chan Envelope[T], opts ...Option) <-chan Envelope[[]T] {
+	return collectBatches(ctx, newConfig("CollectEnvelopes", opts), maxSize, maxDuration, in, batch[T])
+}
+
+// batch puts the values of envelopes in a single envelope that acks them all
+func batch[T any](es []Envelope[T]) Envelope[[]T] {
+	values := make([]T, len(es))
+	for i := range es {
+		values[i] = es[i].Value
+	}
+	return NewEnvelope(values, func(err error) {
+		for _, e := range es {
+			e.done(err)
+		}
+	})
+}
+
+// SplitEnvelopes splits batches into their values like Split, putting each value in its own envelope.
+// The batch is acked once all of its values are acked, or nacked with the first error once they are all acked or nacked.
+// Empty batches are acked straight away.
+func SplitEnvelopes[T any](in <-chan Envelope[[]T], opts ...Option) <-chan Envelope[T] {
+	c := newConfig("SplitEnvelopes", opts)
+	out := make(chan Envelope[T])
+	connect(c, out, in)
+	goStage(context.Background(), c.name, c.kind, func() {
+		defer c.closed()
+		defer close(out)
+		for {
+			b, open := receive(c.node, in)
+			if !open {
+				return
+			}
+			c.metrics.In(c.name)
+			if len(b.Value) == 0 {
+				b.Ack()
+				continue
+			}
+			a := newAcker(len(b.Value), b.done)
+			for _, v := range b.Value {
+				deliver(c.node, out, NewEnvelope(v, a.ack))
+				c.metrics.Out(c.name)
+			}
+		}
+	})
+	return out
+}
+
+// DrainAck empties the input like Drain, acking every envelope
+func DrainAck[T any](in <-chan Envelope[T]) {
+	for e := range in {
+		e.Ack()
+	}
+}
+
+// acker acks a parent envelope once all of its children are done
+type acker struct {
+	mu      sync.Mutex
+	pending int
+	err     error
+	done    func(error)
+}
+
+func newAcker(pending int, done func(error)) *acker {
+	return &acker{pending: pending, done: done}
+}
+
+// ack records that a child is done, keeping the first error a child was nacked with
+func (a *acker) ack(err error) {
+	a.mu.Lock()
+	if a.err == nil {
+		a.err = err
+	}
+	a.pending--
+	pending, err := a.pending, a.err
+	a.mu.Unlock()
+	if pending == 0 {
+		a.done(err)
+	}
+}
@@ -0,0 +1,186 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline/v2/pipelinetest"
+)
+
+// acks records which envelopes were acked and nacked
+type acks struct {
+	mu     sync.Mutex
+	acked  []int
+	nacked map[int]error
+}
+
+func newAcks() *acks {
+	return &acks{nacked: make(map[int]error)}
+}
+
+// envelopes puts each int in an envelope recorded by a
+func (a *acks) envelopes(is ...int) <-chan Envelope[int] {
+	es := make([]Envelope[int], len(is))
+	for n, i := range is {
+		i := i
+		es[n] = NewEnvelope(i, func(err error) {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			if err != nil {
+				a.nacked[i] = err
+				return
+			}
+			a.acked = append(a.acked, i)
+		})
+	}
+	return Emit(es...)
+}
+
+func (a *acks) get() ([]int, map[int]error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	acked := append([]int(nil), a.acked...)
+	sort.Ints(acked)
+	return acked, a.nacked
+}
+
+var errTwo = errors.New("two")
+
+func TestWithAck(t *testing.T) {
+	t.Parallel()
+
+	a := newAcks()
+	processor := pipelinetest.NewProcessor(pipelinetest.FailWhen(func(i int) bool {
+		return i == 2
+	}, errTwo, func(_ context.Context, i int) (int, error) {
+		return i * 10, nil
+	}))
+	out := Process(context.Background(), WithAck[int, int](processor), a.envelopes(1, 2, 3))
+
+	// The outputs are in the envelopes of the inputs
+	var values []int
+	for e := range out {
+		values = append(values, e.Value)
+		e.Ack()
+		e.Ack()
+	}
+	if !reflect.DeepEqual(values, []int{10, 30}) {
+		t.Errorf("values = %v, want [10 30]", values)
+	}
+	acked, nacked := a.get()
+	if !reflect.DeepEqual(acked, []int{1, 3}) {
+		t.Errorf("acked = %v, want [1 3]", acked)
+	}
+	if !reflect.DeepEqual(nacked, map[int]error{2: errTwo}) {
+		t.Errorf("nacked = %v, want 2: %s", nacked, errTwo)
+	}
+}
+
+func TestWithAckApply(t *testing.T) {
+	t.Parallel()
+
+	a := newAcks()
+	// Each input is split in two, and the second half of 2 fails
+	processor := Apply(NewProcessor(func(_ context.Context, i int) ([]int, error) {
+		return []int{i * 10, i*10 + 1}, nil
+	}, func(int, error) {}), NewProcessor(func(_ context.Context, i int) (int, error) {
+		if i == 21 {
+			return i, errTwo
+		}
+		return i, nil
+	}, func(int, error) {}))
+	DrainAck(SplitEnvelopes(Process(context.Background(), WithAck(processor), a.envelopes(1, 2, 3))))
+
+	acked, nacked := a.get()
+	if !reflect.DeepEqual(acked, []int{1, 3}) {
+		t.Errorf("acked = %v, want [1 3]", acked)
+	}
+	if !reflect.DeepEqual(nacked, map[int]error{2: errTwo}) {
+		t.Errorf("nacked = %v, want 2: %s", nacked, errTwo)
+	}
+}
+
+func TestApplyEnvelopes(t *testing.T) {
+	t.Parallel()
+
+	a := newAcks()
+	// Each input is split in two, except 3 which has nothing in it, and the second half of 4 fails
+	processor := ApplyEnvelopes(NewProcessor(func(_ context.Context, i int) ([]int, error) {
+		if i == 3 {
+			return nil, nil
+		}
+		return []int{i * 10, i*10 + 1}, nil
+	}, func(int, error) {}), NewProcessor(func(_ context.Context, i int) (int, error) {
+		if i == 41 {
+			return i, errTwo
+		}
+		return i, nil
+	}, func(int, error) {}))
+	out := Split(Process(context.Background(), processor, a.envelopes(1, 2, 3, 4)))
+
+	// Each output is acked or nacked on its own, and 2 is nacked once both of its outputs are done
+	var values []int
+	for e := range out {
+		values = append(values, e.Value)
+		if e.Value == 21 {
+			e.Nack(errTwo)
+			continue
+		}
+		e.Ack()
+	}
+	if !reflect.DeepEqual(values, []int{10, 11, 20, 21}) {
+		t.Errorf("values = %v, want [10 11 20 21]", values)
+	}
+	acked, nacked := a.get()
+	if !reflect.DeepEqual(acked, []int{1, 3}) {
+		t.Errorf("acked = %v, want [1 3]", acked)
+	}
+	if !reflect.DeepEqual(nacked, map[int]error{2: errTwo, 4: errTwo}) {
+		t.Errorf("nacked = %v, want 2 and 4: %s", nacked, errTwo)
+	}
+}
+
+func TestCollectAndSplitEnvelopes(t *testing.T) {
+	t.Parallel()
+
+	a := newAcks()
+	batches := CollectEnvelopes(context.Background(), 2, time.Second, a.envelopes(1, 2, 3, 4))
+	es := pipelinetest.Collect(t, SplitEnvelopes(batches), time.Second)
+	if len(es) != 4 {
+		t.Fatalf("got %d envelopes, want 4", len(es))
+	}
+
+	// A batch is only acked once all of its values are
+	es[0].Ack()
+	if acked, _ := a.get(); len(acked) != 0 {
+		t.Errorf("acked = %v before the batch was done", acked)
+	}
+	es[1].Ack()
+	if acked, _ := a.get(); !reflect.DeepEqual(acked, []int{1, 2}) {
+		t.Errorf("acked = %v, want [1 2]", acked)
+	}
+
+	// And it is nacked if any of them are
+	es[2].Nack(errTwo)
+	es[3].Ack()
+	if acked, nacked := a.get(); len(acked) != 2 || !reflect.DeepEqual(nacked, map[int]error{3: errTwo, 4: errTwo}) {
+		t.Errorf("acked = %v, nacked = %v, want 3 and 4 nacked", acked, nacked)
+	}
+}
+
+func TestSplitEnvelopesEmptyBatch(t *testing.T) {
+	t.Parallel()
+
+	var acked bool
+	DrainAck(SplitEnvelopes(Emit(NewEnvelope([]int{}, func(err error) {
+		acked = err == nil
+	}))))
+	if !acked {
+		t.Error("empty batch was not acked")
+	}
+}
@@ -1,3 +1,3 @@
 module github.com/deliveryhero/pipeline/v2
 
-go 1.18
+go 1.21
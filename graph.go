@@ -0,0 +1,337 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/deliveryhero/pipeline/v2/internal/clock"
+	"github.com/deliveryhero/pipeline/v2/semaphore"
+)
+
+// Graph is the topology of the stages of a pipeline.
+// Stages configured WithGraph are added to the graph, along with live counters of the items passing through them.
+// Stages that can't be configured with options, like Merge, can be added with Register.
+type Graph struct {
+	mu    sync.Mutex
+	nodes []*node
+}
+
+// NewGraph creates an empty Graph
+func NewGraph() *Graph {
+	return &Graph{}
+}
+
+// WithGraph adds a stage to the graph
+func WithGraph(g *Graph) Option {
+	return func(c *config) {
+		c.graph = g
+	}
+}
+
+// Register adds a stage named `name` of the given `kind` to the graph.
+// The stage reads from the `ins` channels, which may be of any item type, and writes to `out`.
+// It panics if one of the `ins` is not a channel that can be received from, since it could never be connected to the stage that writes to it.
+// It returns a channel that passes through everything from `out`, so it can be counted.
+// Every item is counted in when it is received from `out`, and out when it is passed on.
+// That costs a goroutine and a hand-off per item, which the stages configured WithGraph do not need.
+func Register[Item any](g *Graph, name, kind string, out <-chan Item, ins ...any) <-chan Item {
+	recv := make([]any, len(ins))
+	for k, in := range ins {
+		recv[k] = receiveOnly(in)
+	}
+	counted := make(chan Item)
+	n := g.add(name, kind, clock.Real())
+	n.connect((<-chan Item)(counted), recv...)
+	goStage(context.Background(), name, kind, func() {
+		defer close(counted)
+		for {
+			i, open := receive(n, out)
+			if !open {
+				return
+			}
+			n.In(name)
+			deliver(n, counted, i)
+			n.Out(name)
+		}
+	})
+	return counted
+}
+
+// receiveOnly converts a channel to a receive only channel, which is what the stages of a graph write to.
+// It panics if `in` is not a channel or can't be received from.
+func receiveOnly(in any) any {
+	v := reflect.ValueOf(in)
+	if v.Kind() != reflect.Chan || v.Type().ChanDir()&reflect.RecvDir == 0 {
+		panic(fmt.Sprintf("pipeline: Register was given %T as an in, rather than a channel to receive from", in))
+	}
+	return v.Convert(reflect.ChanOf(reflect.RecvDir, v.Type().Elem())).Interface()
+}
+
+// StageStats is a snapshot of the counters of a stage in a Graph
+type StageStats struct {
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	In       int64  `json:"in"`
+	Out      int64  `json:"out"`
+	Errors   int64  `json:"errors"`
+	Canceled int64  `json:"canceled"`
+	Dropped  int64  `json:"dropped"`
+	InFlight int64  `json:"inFlight"`
+}
+
+// Stats returns the counters of every stage in the order they were added to the graph
+func (g *Graph) Stats() []StageStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	stats := make([]StageStats, len(g.nodes))
+	for i, n := range g.nodes {
+		stats[i] = n.stats()
+	}
+	return stats
+}
+
+// DOT renders the graph in the Graphviz DOT language, labelling each stage with its counters
+func (g *Graph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph pipeline {\n")
+	b.WriteString("\trankdir=LR;\n")
+	g.render(func(id int, s StageStats) {
+		fmt.Fprintf(&b, "\tn%d [label=%q];\n", id, fmt.Sprintf("%s\n%s\n%s", s.Name, s.Kind, counters(s)))
+	}, func(from, to int) {
+		fmt.Fprintf(&b, "\tn%d -> n%d;\n", from, to)
+	})
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Mermaid renders the graph as a Mermaid flowchart, labelling each stage with its counters
+func (g *Graph) Mermaid() string {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	g.render(func(id int, s StageStats) {
+		label := strings.ReplaceAll(fmt.Sprintf("%s<br/>%s<br/>%s", s.Name, s.Kind, counters(s)), `"`, "#quot;")
+		fmt.Fprintf(&b, "\tn%d[\"%s\"]\n", id, label)
+	}, func(from, to int) {
+		fmt.Fprintf(&b, "\tn%d --> n%d\n", from, to)
+	})
+	return b.String()
+}
+
+// render calls node for every stage, then edge for every connection between two stages
+func (g *Graph) render(node func(id int, s StageStats), edge func(from, to int)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	producers := make(map[any]int, len(g.nodes))
+	for id, n := range g.nodes {
+		node(id, n.stats())
+		if out := n.output(); out != nil {
+			producers[out] = id
+		}
+	}
+	for id, n := range g.nodes {
+		for _, in := range n.inputs() {
+			if from, ok := producers[in]; ok {
+				edge(from, id)
+			}
+		}
+	}
+}
+
+// counters formats the counters of a stage for a label
+func counters(s StageStats) string {
+	c := fmt.Sprintf("in: %d, out: %d", s.In, s.Out)
+	if s.InFlight != 0 {
+		c += fmt.Sprintf(", in flight: %d", s.InFlight)
+	}
+	if s.Errors != 0 {
+		c += fmt.Sprintf(", errors: %d", s.Errors)
+	}
+	if s.Canceled != 0 {
+		c += fmt.Sprintf(", canceled: %d", s.Canceled)
+	}
+	if s.Dropped != 0 {
+		c += fmt.Sprintf(", dropped: %d", s.Dropped)
+	}
+	return c
+}
+
+// add adds a node to the graph
+func (g *Graph) add(name, kind string, clock Clock) *node {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	n := &node{name: name, kind: kind, clock: clock}
+	g.nodes = append(g.nodes, n)
+	return n
+}
+
+// node is a stage in a Graph. It counts the items passing through the stage by implementing Metrics.
+type node struct {
+	name  string
+	kind  string
+	clock Clock
+
+	mu  sync.Mutex
+	ins []any
+	out any
+	sem semaphore.Semaphore
+
+	in           int64
+	outs         int64
+	errs         int64
+	canceled     int64
+	dropped      int64
+	inFlight     int64
+	receiving    int64
+	sending      int64
+	paused       int64
+	held         int64
+	lastActivity int64
+}
+
+// connect sets the channels the stage reads from and writes to
+func (n *node) connect(out any, ins ...any) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.ins, n.out = ins, out
+}
+
+func (n *node) inputs() []any {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.ins
+}
+
+func (n *node) output() any {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.out
+}
+
+// limit sets the semaphore that limits the concurrency of the stage
+func (n *node) limit(sem semaphore.Semaphore) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sem = sem
+}
+
+// active records that the stage made progress
+func (n *node) active() {
+	atomic.StoreInt64(&n.lastActivity, n.clock.Now().UnixNano())
+}
+
+// blockedOnReceive adds delta to the number of goroutines of the stage that are waiting to receive an item
+func (n *node) blockedOnReceive(delta int64) {
+	if n != nil {
+		atomic.AddInt64(&n.receiving, delta)
+	}
+}
+
+// blockedOnSend adds delta to the number of goroutines of the stage that are waiting to send an item
+func (n *node) blockedOnSend(delta int64) {
+	if n != nil {
+		atomic.AddInt64(&n.sending, delta)
+	}
+}
+
+// pause records whether the stage is paused by a Valve
+func (n *node) pause(paused bool) {
+	if n == nil {
+		return
+	}
+	var p int64
+	if paused {
+		p = 1
+	}
+	atomic.StoreInt64(&n.paused, p)
+}
+
+// hold adds delta to the number of items the stage holds until it passes them on, like the partial batch of Collect
+func (n *node) hold(delta int64) {
+	if n != nil {
+		atomic.AddInt64(&n.held, delta)
+	}
+}
+
+// receive receives an item from in, recording that the stage is blocked until it does
+func receive[Item any](n *node, in <-chan Item) (Item, bool) {
+	n.blockedOnReceive(1)
+	defer n.blockedOnReceive(-1)
+	i, open := <-in
+	return i, open
+}
+
+// receiveOrDone receives an item from in unless the context is canceled first, recording that the stage is blocked until it does.
+// It returns false if the context was canceled.
+func receiveOrDone[Item any](ctx context.Context, n *node, in <-chan Item) (i Item, open, ok bool) {
+	n.blockedOnReceive(1)
+	defer n.blockedOnReceive(-1)
+	select {
+	case <-ctx.Done():
+		return i, false, false
+	case i, open = <-in:
+		return i, open, true
+	}
+}
+
+// deliver sends an item to out, recording that the stage is blocked until it does
+func deliver[Item any](n *node, out chan<- Item, i Item) {
+	n.blockedOnSend(1)
+	defer n.blockedOnSend(-1)
+	out <- i
+}
+
+func (n *node) stats() StageStats {
+	return StageStats{
+		Name:     n.name,
+		Kind:     n.kind,
+		In:       atomic.LoadInt64(&n.in),
+		Out:      atomic.LoadInt64(&n.outs),
+		Errors:   atomic.LoadInt64(&n.errs),
+		Canceled: atomic.LoadInt64(&n.canceled),
+		Dropped:  atomic.LoadInt64(&n.dropped),
+		InFlight: atomic.LoadInt64(&n.inFlight),
+	}
+}
+
+func (n *node) In(string) {
+	atomic.AddInt64(&n.in, 1)
+	n.active()
+}
+
+func (n *node) Out(string) {
+	atomic.AddInt64(&n.outs, 1)
+	n.active()
+}
+
+func (n *node) Process(_ string, _ time.Duration, err error) {
+	if err != nil {
+		atomic.AddInt64(&n.errs, 1)
+	}
+	n.active()
+}
+
+func (n *node) Cancel(string, error) {
+	atomic.AddInt64(&n.canceled, 1)
+	n.active()
+}
+
+func (n *node) InFlight(_ string, delta int) {
+	atomic.AddInt64(&n.inFlight, int64(delta))
+}
+
+func (n *node) Batch(string, int) {}
+
+func (n *node) QueueWait(string, time.Duration) {}
+
+func (n *node) Drop(string) {
+	atomic.AddInt64(&n.dropped, 1)
+	n.active()
+}
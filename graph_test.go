@@ -0,0 +1,112 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestGraph runs a pipeline that merges two sources, processes, collects and splits them in a graph
+func newTestGraph() *Graph {
+	ctx := context.Background()
+	g := NewGraph()
+	odds := Register(g, "odds", "Emit", Emit(1, 3, 5))
+	evens := Register(g, "evens", "Emit", Emit(2, 4))
+	p := Register(g, "merge", "Merge", Merge(odds, evens), odds, evens)
+	p = Process(ctx, NewProcessor(func(_ context.Context, i int) (int, error) {
+		if i == 5 {
+			return 0, errors.New("5 is not allowed")
+		}
+		return i, nil
+	}, func(int, error) {}), p, WithName("filter"), WithGraph(g))
+	batches := Collect(ctx, 2, time.Minute, p, WithName("batch"), WithGraph(g))
+	Drain(Split(batches, WithGraph(g)))
+	return g
+}
+
+func TestGraphStats(t *testing.T) {
+	t.Parallel()
+
+	want := []StageStats{
+		{Name: "odds", Kind: "Emit", In: 3, Out: 3},
+		{Name: "evens", Kind: "Emit", In: 2, Out: 2},
+		{Name: "merge", Kind: "Merge", In: 5, Out: 5},
+		{Name: "filter", Kind: "Process", In: 5, Out: 4, Errors: 1, Canceled: 1},
+		{Name: "batch", Kind: "Collect", In: 4, Out: 2},
+		{Name: "Split", Kind: "Split", In: 2, Out: 4},
+	}
+	if got := newTestGraph().Stats(); !reflect.DeepEqual(want, got) {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestGraphDOT(t *testing.T) {
+	t.Parallel()
+
+	want := `digraph pipeline {
+	rankdir=LR;
+	n0 [label="odds\nEmit\nin: 3, out: 3"];
+	n1 [label="evens\nEmit\nin: 2, out: 2"];
+	n2 [label="merge\nMerge\nin: 5, out: 5"];
+	n3 [label="filter\nProcess\nin: 5, out: 4, errors: 1, canceled: 1"];
+	n4 [label="batch\nCollect\nin: 4, out: 2"];
+	n5 [label="Split\nSplit\nin: 2, out: 4"];
+	n0 -> n2;
+	n1 -> n2;
+	n2 -> n3;
+	n3 -> n4;
+	n4 -> n5;
+}
+`
+	if got := newTestGraph().DOT(); got != want {
+		t.Errorf("DOT() = %s, want %s", got, want)
+	}
+}
+
+func TestGraphMermaid(t *testing.T) {
+	t.Parallel()
+
+	want := `flowchart LR
+	n0["odds<br/>Emit<br/>in: 3, out: 3"]
+	n1["evens<br/>Emit<br/>in: 2, out: 2"]
+	n2["merge<br/>Merge<br/>in: 5, out: 5"]
+	n3["filter<br/>Process<br/>in: 5, out: 4, errors: 1, canceled: 1"]
+	n4["batch<br/>Collect<br/>in: 4, out: 2"]
+	n5["Split<br/>Split<br/>in: 2, out: 4"]
+	n0 --> n2
+	n1 --> n2
+	n2 --> n3
+	n3 --> n4
+	n4 --> n5
+`
+	if got := newTestGraph().Mermaid(); got != want {
+		t.Errorf("Mermaid() = %s, want %s", got, want)
+	}
+}
+
+func TestRegisterIns(t *testing.T) {
+	t.Parallel()
+
+	// A chan that is not receive only is connected all the same
+	c := make(chan int)
+	if got := receiveOnly(c); got != any((<-chan int)(c)) {
+		t.Errorf("receiveOnly(%T) = %T, want the same chan as a <-chan int", c, got)
+	}
+
+	for _, in := range []any{nil, 1, []int{1}, make(chan<- int)} {
+		in := in
+		t.Run(fmt.Sprintf("%T", in), func(t *testing.T) {
+			t.Parallel()
+
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register did not panic when given %T as an in", in)
+				}
+			}()
+			Register(NewGraph(), "merge", "Merge", Merge[int](), in)
+		})
+	}
+}
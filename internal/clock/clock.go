@@ -0,0 +1,73 @@
+// Package clock abstracts time, so the stages of a pipeline that depend on it can be tested without sleeping.
+// The types are aliased by the pipeline and pipelinetest packages.
+package clock
+
+import "time"
+
+// Clock tells the time and creates timers and tickers
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+
+	// NewTimer creates a Timer that sends the current time on its channel after at least `d`
+	NewTimer(d time.Duration) Timer
+
+	// NewTicker creates a Ticker that sends the current time on its channel every `d`
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is like a `time.Timer`
+type Timer interface {
+	// C returns the channel the time is sent on
+	C() <-chan time.Time
+
+	// Stop prevents the timer from firing. It returns false if the timer already fired or was stopped.
+	Stop() bool
+
+	// Reset makes the timer fire after `d` instead. It returns true if the timer had been active.
+	Reset(d time.Duration) bool
+}
+
+// Ticker is like a `time.Ticker`
+type Ticker interface {
+	// C returns the channel the ticks are sent on
+	C() <-chan time.Time
+
+	// Stop turns off the ticker
+	Stop()
+}
+
+// Real returns the Clock of the time package
+func Real() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
@@ -0,0 +1,177 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake is a Clock whose time only changes when it is advanced.
+// Its timers and tickers fire as the time passes the time they are due.
+type Fake struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	now     time.Time
+	timers  []*fakeTimer
+}
+
+// NewFake creates a Fake clock set to `now`
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.changed = sync.NewCond(&f.mu)
+	return f
+}
+
+// Now returns the time the clock is set to
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// NewTimer creates a Timer that fires once the clock is advanced by `d`
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	return f.add(d, 0)
+}
+
+// NewTicker creates a Ticker that fires every time the clock is advanced by `d`
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	return fakeTicker{f.add(d, d)}
+}
+
+// Advance moves the clock forward by `d`, firing the timers and tickers that are due in order
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	end := f.now.Add(d)
+	for {
+		next := f.next()
+		if next == nil || next.at.After(end) {
+			break
+		}
+		f.now = next.at
+		if next.fire(); !next.active {
+			f.remove(next)
+		}
+	}
+	f.now = end
+	f.changed.Broadcast()
+}
+
+// BlockUntil blocks until `n` timers and tickers are waiting to fire.
+// Use it to wait for a stage to start waiting before advancing the clock.
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for f.waiting() < n {
+		f.changed.Wait()
+	}
+}
+
+// Waiting returns the number of timers and tickers that are waiting to fire
+func (f *Fake) Waiting() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.waiting()
+}
+
+func (f *Fake) waiting() int {
+	var n int
+	for _, t := range f.timers {
+		if t.active {
+			n++
+		}
+	}
+	return n
+}
+
+// next returns the active timer that is due first
+func (f *Fake) next() *fakeTimer {
+	var next *fakeTimer
+	for _, t := range f.timers {
+		if t.active && (next == nil || t.at.Before(next.at)) {
+			next = t
+		}
+	}
+	return next
+}
+
+func (f *Fake) add(d, period time.Duration) *fakeTimer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &fakeTimer{
+		clock:  f,
+		c:      make(chan time.Time, 1),
+		at:     f.now.Add(d),
+		period: period,
+		active: true,
+	}
+	f.timers = append(f.timers, t)
+	f.changed.Broadcast()
+	return t
+}
+
+// fakeTimer is a timer, or a ticker when it has a period
+type fakeTimer struct {
+	clock  *Fake
+	c      chan time.Time
+	at     time.Time
+	period time.Duration
+	active bool
+}
+
+// fire sends the time unless a previous time wasn't received yet, like the tickers of the time package do
+func (t *fakeTimer) fire() {
+	select {
+	case t.c <- t.at:
+	default:
+	}
+	if t.period > 0 {
+		t.at = t.at.Add(t.period)
+	} else {
+		t.active = false
+	}
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	active := t.active
+	t.active = false
+	t.clock.remove(t)
+	return active
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	active := t.active
+	if !active {
+		t.clock.timers = append(t.clock.timers, t)
+	}
+	t.at, t.active = t.clock.now.Add(d), true
+	t.clock.changed.Broadcast()
+	return active
+}
+
+// fakeTicker is a fakeTimer with a period
+type fakeTicker struct {
+	*fakeTimer
+}
+
+func (t fakeTicker) Stop() {
+	t.fakeTimer.Stop()
+}
+
+// remove forgets a timer that was stopped or fired
+func (f *Fake) remove(t *fakeTimer) {
+	for i, other := range f.timers {
+		if other == t {
+			f.timers = append(f.timers[:i], f.timers[i+1:]...)
+			return
+		}
+	}
+}
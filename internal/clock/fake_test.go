@@ -0,0 +1,89 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+// received returns the times that were sent to c
+func received(c <-chan time.Time) []time.Time {
+	var ts []time.Time
+	for {
+		select {
+		case t := <-c:
+			ts = append(ts, t)
+		default:
+			return ts
+		}
+	}
+}
+
+func TestFakeTimer(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	timer := f.NewTimer(time.Second)
+	if n := f.Waiting(); n != 1 {
+		t.Fatalf("waiting = %d, want 1", n)
+	}
+
+	// The timer fires once its time has passed
+	f.Advance(999 * time.Millisecond)
+	if ts := received(timer.C()); len(ts) != 0 {
+		t.Errorf("timer fired early at %v", ts)
+	}
+	f.Advance(time.Millisecond)
+	if ts := received(timer.C()); len(ts) != 1 || !ts[0].Equal(start.Add(time.Second)) {
+		t.Errorf("timer fired at %v, want once at %s", ts, start.Add(time.Second))
+	}
+	if timer.Stop() {
+		t.Error("stop returned true after the timer fired")
+	}
+
+	// A timer that is reset fires again
+	if timer.Reset(time.Second) {
+		t.Error("reset returned true after the timer fired")
+	}
+	if !timer.Stop() {
+		t.Error("stop returned false for an active timer")
+	}
+	f.Advance(time.Hour)
+	if ts := received(timer.C()); len(ts) != 0 {
+		t.Errorf("stopped timer fired at %v", ts)
+	}
+	if n := f.Waiting(); n != 0 {
+		t.Errorf("waiting = %d, want 0", n)
+	}
+	if now := f.Now(); !now.Equal(start.Add(time.Second + time.Hour)) {
+		t.Errorf("now = %s, want %s", now, start.Add(time.Second+time.Hour))
+	}
+}
+
+func TestFakeTicker(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	ticker := f.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	f.Advance(time.Second)
+	if ts := received(ticker.C()); len(ts) != 1 || !ts[0].Equal(start.Add(time.Second)) {
+		t.Errorf("ticks = %v, want one at %s", ts, start.Add(time.Second))
+	}
+	// Ticks that aren't received are dropped
+	f.Advance(3 * time.Second)
+	if ts := received(ticker.C()); len(ts) != 1 || !ts[0].Equal(start.Add(2*time.Second)) {
+		t.Errorf("ticks = %v, want one at %s", ts, start.Add(2*time.Second))
+	}
+}
+
+func TestFakeBlockUntil(t *testing.T) {
+	f := NewFake(time.Now())
+	go func() {
+		<-f.NewTimer(time.Second).C()
+	}()
+	// Wait for the goroutine to start waiting
+	f.BlockUntil(1)
+	f.Advance(time.Second)
+	if n := f.Waiting(); n != 0 {
+		t.Errorf("waiting = %d, want 0", n)
+	}
+}
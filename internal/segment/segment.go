@@ -0,0 +1,359 @@
+// Package segment is an append-only log of records, split into segment files that can be removed once they are read.
+// It keeps the items of the stages that hold them on disk, like SpillBuffer and the durable queue.
+package segment
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// headerSize is the size of the length and the checksum in front of every record
+const headerSize = 8
+
+// maxRecordSize is the size of the largest record, so a corrupt length can't exhaust the memory
+const maxRecordSize = 1 << 30
+
+// suffix is the extension of the segment files, which are named after their zero padded ID so they sort in order
+const suffix = ".seg"
+
+// ErrCorrupt is returned when a record doesn't match its checksum
+var ErrCorrupt = errors.New("segment: corrupt record")
+
+var table = crc32.MakeTable(crc32.Castagnoli)
+
+// Position is where a record starts in the log
+type Position struct {
+	Segment uint64 `json:"segment"`
+	Offset  int64  `json:"offset"`
+}
+
+// Less returns true if p is before o in the log
+func (p Position) Less(o Position) bool {
+	return p.Segment < o.Segment || p.Segment == o.Segment && p.Offset < o.Offset
+}
+
+// Log appends records to the last of its segments, starting a new one once it is larger than the maximum size.
+// It is safe for concurrent use.
+type Log struct {
+	dir     string
+	maxSize int64
+
+	mu    sync.Mutex
+	first uint64
+	last  uint64
+	w     *os.File
+	size  int64
+}
+
+// Open opens the log in `dir`, creating the directory if it doesn't exist.
+// A record that was only partly written to the last segment when the process crashed is truncated.
+func Open(dir string, maxSize int64) (*Log, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	ids, err := segments(dir)
+	if err != nil {
+		return nil, err
+	}
+	l := &Log{dir: dir, maxSize: maxSize, first: 1, last: 1}
+	if len(ids) > 0 {
+		l.first, l.last = ids[0], ids[len(ids)-1]
+	}
+	l.w, err = os.OpenFile(l.path(l.last), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if l.size, err = validSize(l.w); err != nil {
+		l.w.Close()
+		return nil, err
+	}
+	if err := l.w.Truncate(l.size); err != nil {
+		l.w.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Append appends a record to the log and returns its position.
+// The record is written but not synced to disk, see Sync.
+func (l *Log) Append(record []byte) (Position, error) {
+	if len(record) > maxRecordSize {
+		return Position{}, fmt.Errorf("segment: record of %d bytes is larger than %d bytes", len(record), maxRecordSize)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.size > 0 && l.size+headerSize+int64(len(record)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return Position{}, err
+		}
+	}
+	buf := make([]byte, headerSize+len(record))
+	binary.BigEndian.PutUint32(buf, uint32(len(record)))
+	binary.BigEndian.PutUint32(buf[4:], crc32.Checksum(record, table))
+	copy(buf[headerSize:], record)
+	pos := Position{Segment: l.last, Offset: l.size}
+	if _, err := l.w.WriteAt(buf, l.size); err != nil {
+		return Position{}, err
+	}
+	l.size += int64(len(buf))
+	return pos, nil
+}
+
+// Read reads the record at `pos`, which must have been returned by Append or Reader.Next
+func (l *Log) Read(pos Position) ([]byte, error) {
+	l.mu.Lock()
+	limit := int64(-1)
+	if pos.Segment == l.last {
+		limit = l.size
+	}
+	l.mu.Unlock()
+	f, err := os.Open(l.path(pos.Segment))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	record, _, err := readRecord(f, pos.Offset, limit)
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return record, err
+}
+
+// Last returns the ID of the segment that is appended to
+func (l *Log) Last() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.last
+}
+
+// Sync syncs the last segment to disk
+func (l *Log) Sync() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Sync()
+}
+
+// End returns the position the next record will be appended at
+func (l *Log) End() Position {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return Position{Segment: l.last, Offset: l.size}
+}
+
+// First returns the ID of the first segment of the log
+func (l *Log) First() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.first
+}
+
+// Remove removes the segments before the segment `before`. The last segment is never removed.
+func (l *Log) Remove(before uint64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if before > l.last {
+		before = l.last
+	}
+	for ; l.first < before; l.first++ {
+		if err := os.Remove(l.path(l.first)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close syncs and closes the log
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.w.Sync(); err != nil {
+		l.w.Close()
+		return err
+	}
+	return l.w.Close()
+}
+
+// rotate starts a new segment
+func (l *Log) rotate() error {
+	if err := l.w.Sync(); err != nil {
+		return err
+	}
+	if err := l.w.Close(); err != nil {
+		return err
+	}
+	w, err := os.OpenFile(l.path(l.last+1), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	l.w, l.size = w, 0
+	l.last++
+	return syncDir(l.dir)
+}
+
+func (l *Log) path(id uint64) string {
+	return filepath.Join(l.dir, fmt.Sprintf("%020d%s", id, suffix))
+}
+
+// Reader reads the records of a Log in order, starting from a position.
+// It is not safe for concurrent use.
+type Reader struct {
+	log *Log
+	pos Position
+	f   *os.File
+}
+
+// NewReader creates a Reader that reads from `pos`. Positions before the first segment start at the first segment.
+func (l *Log) NewReader(pos Position) *Reader {
+	return &Reader{log: l, pos: pos}
+}
+
+// Position returns the position of the next record
+func (r *Reader) Position() Position {
+	return r.pos
+}
+
+// Next returns the next record and its position. It returns io.EOF when every record appended so far was read.
+// It returns ErrCorrupt if the record is corrupt, in which case Skip moves on to the next segment.
+func (r *Reader) Next() ([]byte, Position, error) {
+	for {
+		r.log.mu.Lock()
+		first, last, end := r.log.first, r.log.last, r.log.size
+		r.log.mu.Unlock()
+		if r.pos.Segment < first {
+			r.moveTo(first)
+		}
+		// The last segment is only read up to what was appended, in case a record is being written
+		limit := int64(-1)
+		if r.pos.Segment == last {
+			limit = end
+			if r.pos.Offset >= end {
+				return nil, r.pos, io.EOF
+			}
+		}
+		if r.f == nil {
+			f, err := os.Open(r.log.path(r.pos.Segment))
+			if err != nil {
+				return nil, r.pos, err
+			}
+			r.f = f
+		}
+		record, size, err := readRecord(r.f, r.pos.Offset, limit)
+		if err == io.EOF && r.pos.Segment == last {
+			return nil, r.pos, io.EOF
+		} else if err == io.EOF {
+			// The end of a segment that is no longer written to
+			r.moveTo(r.pos.Segment + 1)
+			continue
+		} else if err != nil {
+			return nil, r.pos, err
+		}
+		pos := r.pos
+		r.pos.Offset += size
+		return record, pos, nil
+	}
+}
+
+// Skip moves the reader to the start of the next segment, skipping the rest of the current one
+func (r *Reader) Skip() {
+	r.moveTo(r.pos.Segment + 1)
+}
+
+// Close closes the segment the reader is reading
+func (r *Reader) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+// moveTo closes the segment the reader is reading and moves it to the start of another one
+func (r *Reader) moveTo(segment uint64) {
+	r.Close()
+	r.pos = Position{Segment: segment}
+}
+
+// readRecord reads the record at `offset`, returning it and its size including the header.
+// It returns io.EOF if there is no complete record at `offset`, or if `offset` is `limit`.
+func readRecord(f *os.File, offset, limit int64) ([]byte, int64, error) {
+	header := make([]byte, headerSize)
+	if _, err := f.ReadAt(header, offset); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, 0, io.EOF
+		}
+		return nil, 0, err
+	}
+	size := int64(binary.BigEndian.Uint32(header))
+	if size > maxRecordSize {
+		return nil, 0, ErrCorrupt
+	}
+	if limit >= 0 && offset+headerSize+size > limit {
+		return nil, 0, io.EOF
+	}
+	record := make([]byte, size)
+	if _, err := f.ReadAt(record, offset+headerSize); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, 0, io.EOF
+		}
+		return nil, 0, err
+	}
+	if crc32.Checksum(record, table) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, 0, ErrCorrupt
+	}
+	return record, headerSize + size, nil
+}
+
+// validSize returns the size of the complete and valid records at the start of a segment
+func validSize(f *os.File) (int64, error) {
+	var offset int64
+	for {
+		_, size, err := readRecord(f, offset, -1)
+		if err == io.EOF || err == ErrCorrupt {
+			return offset, nil
+		} else if err != nil {
+			return 0, err
+		}
+		offset += size
+	}
+}
+
+// segments returns the IDs of the segments in the directory in order
+func segments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []uint64
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), suffix) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(e.Name(), suffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// syncDir syncs a directory, so the files created in it survive a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,164 @@
+package segment
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// readAll reads the records from the reader until io.EOF
+func readAll(t *testing.T, r *Reader) []string {
+	t.Helper()
+	var records []string
+	for {
+		record, _, err := r.Next()
+		if err == io.EOF {
+			return records
+		} else if err != nil {
+			t.Fatalf("Next() = %s", err)
+		}
+		records = append(records, string(record))
+	}
+}
+
+// appendAll appends the records to the log
+func appendAll(t *testing.T, l *Log, records ...string) {
+	t.Helper()
+	for _, record := range records {
+		if _, err := l.Append([]byte(record)); err != nil {
+			t.Fatalf("Append(%s) = %s", record, err)
+		}
+	}
+}
+
+func TestLog(t *testing.T) {
+	dir := t.TempDir()
+	// Every segment holds two records
+	l, err := Open(dir, 2*(headerSize+2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendAll(t, l, "r1", "r2", "r3")
+	r := l.NewReader(Position{})
+	if records := readAll(t, r); !reflect.DeepEqual(records, []string{"r1", "r2", "r3"}) {
+		t.Errorf("records = %v", records)
+	}
+
+	// The reader picks up where it left off
+	appendAll(t, l, "r4", "r5")
+	if records := readAll(t, r); !reflect.DeepEqual(records, []string{"r4", "r5"}) {
+		t.Errorf("records = %v, want [r4 r5]", records)
+	}
+	if end := l.End(); end != r.Position() || end.Segment != 3 {
+		t.Errorf("end = %+v, reader is at %+v, want segment 3", end, r.Position())
+	}
+	r.Close()
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Removed segments are skipped, and the log is appended to after it is reopened
+	l, err = Open(dir, 2*(headerSize+2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if err := l.Remove(2); err != nil {
+		t.Fatal(err)
+	}
+	appendAll(t, l, "r6")
+	if records := readAll(t, l.NewReader(Position{})); !reflect.DeepEqual(records, []string{"r3", "r4", "r5", "r6"}) {
+		t.Errorf("records = %v, want [r3 r4 r5 r6]", records)
+	}
+	if first := l.First(); first != 2 {
+		t.Errorf("first = %d, want 2", first)
+	}
+}
+
+func TestLogTruncatesTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendAll(t, l, "complete")
+	end := l.End()
+	l.Close()
+
+	// A crash in the middle of writing a record leaves only part of it
+	f, err := os.OpenFile(filepath.Join(dir, fmt.Sprintf("%020d%s", 1, suffix)), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 0, 10, 1, 2})
+	f.Close()
+
+	l, err = Open(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if got := l.End(); got != end {
+		t.Errorf("end = %+v, want %+v", got, end)
+	}
+	appendAll(t, l, "next")
+	if records := readAll(t, l.NewReader(Position{})); !reflect.DeepEqual(records, []string{"complete", "next"}) {
+		t.Errorf("records = %v, want [complete next]", records)
+	}
+}
+
+func TestReaderSkipsCorruptSegment(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, headerSize+2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	appendAll(t, l, "r1", "r2")
+
+	// Flip a byte of the first record
+	path := filepath.Join(dir, fmt.Sprintf("%020d%s", 1, suffix))
+	data, _ := os.ReadFile(path)
+	data[headerSize] ^= 0xff
+	os.WriteFile(path, data, 0o644)
+
+	r := l.NewReader(Position{})
+	if _, _, err := r.Next(); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("Next() = %v, want %s", err, ErrCorrupt)
+	}
+	r.Skip()
+	if records := readAll(t, r); !reflect.DeepEqual(records, []string{"r2"}) {
+		t.Errorf("records = %v, want [r2]", records)
+	}
+}
+
+func TestLogRead(t *testing.T) {
+	l, err := Open(t.TempDir(), headerSize+2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	var positions []Position
+	for _, record := range []string{"r1", "r2"} {
+		pos, err := l.Append([]byte(record))
+		if err != nil {
+			t.Fatal(err)
+		}
+		positions = append(positions, pos)
+	}
+	if last := l.Last(); last != 2 {
+		t.Errorf("last = %d, want 2", last)
+	}
+	for i, want := range []string{"r1", "r2"} {
+		if record, err := l.Read(positions[i]); err != nil || string(record) != want {
+			t.Errorf("Read(%+v) = %s, %v, want %s", positions[i], record, err, want)
+		}
+	}
+	if _, err := l.Read(l.End()); err != io.ErrUnexpectedEOF {
+		t.Errorf("Read() past the end = %v, want %s", err, io.ErrUnexpectedEOF)
+	}
+}
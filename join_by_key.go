@@ -0,0 +1,248 @@
+package pipeline
+
+import (
+	"container/list"
+	"context"
+	"time"
+)
+
+// defaultJoinSize is the number of items JoinByKey holds at most, unless it is configured WithMaxSize
+const defaultJoinSize = 10000
+
+// WithMaxSize limits the number of items held by JoinByKey.
+// It is only read by JoinByKey, and any other stage it is given to panics when it is created.
+func WithMaxSize(n int) Option {
+	return func(c *config) {
+		c.maxSize = n
+		c.stageOption("WithMaxSize")
+	}
+}
+
+// WithUnmatched makes JoinByKey perform a left outer join, by passing its left items that were never matched to `f`.
+// `L` must be the type of the left items of the join, otherwise JoinByKey panics when it is created.
+// It is only read by JoinByKey, and any other stage it is given to panics when it is created.
+func WithUnmatched[L any](f func(L)) Option {
+	return func(c *config) {
+		c.unmatched = f
+		c.stageOption("WithUnmatched")
+	}
+}
+
+// JoinByKey joins the items of `left <-chan L` and `right <-chan R` whose keys match and that arrive within `window` of each other.
+// Every match is passed to the out channel as a `Pair[L, R]`.
+// Each item is held for `window` after it arrives, and no more than 10000 items are held at once, unless the join is configured WithMaxSize.
+// When the limit is reached, the oldest item is evicted to make room for the next one.
+//
+// JoinByKey performs an inner join, and left items without a match are dropped.
+// When it is configured WithUnmatched, it performs a left outer join: every left item that has not been matched by the time it expires,
+// is evicted, or the join is shut down is passed to the func set WithUnmatched.
+//
+// The out channel is closed after both inputs are closed or the `Context` is canceled.
+// If the context is canceled, anything remaining in the inputs is drained, so the upstream stages never block.
+func JoinByKey[L, R any, K comparable](
+	ctx context.Context,
+	left <-chan L,
+	right <-chan R,
+	leftKey func(L) K,
+	rightKey func(R) K,
+	window time.Duration,
+	opts ...Option,
+) <-chan Pair[L, R] {
+	c := newConfig("JoinByKey", opts, "WithMaxSize", "WithUnmatched")
+	unmatched := callback[L](c.unmatched, "WithUnmatched")
+	maxSize := c.maxSize
+	if maxSize < 1 {
+		maxSize = defaultJoinSize
+	}
+	out := make(chan Pair[L, R])
+	connect(c, out, left, right)
+	goStage(ctx, c.name, c.kind, func() {
+		defer c.closed()
+		defer close(out)
+		j := &keyedJoin[L, R, K]{
+			config:    c,
+			window:    window,
+			maxSize:   maxSize,
+			unmatched: unmatched,
+			entries:   list.New(),
+			lefts:     make(map[K][]*list.Element),
+			rights:    make(map[K][]*list.Element),
+		}
+		// Nothing can be matched once the join is over
+		defer j.flush()
+
+		expire := newAlarm(c.clock)
+		defer expire.stop()
+
+		// A nil chan blocks forever, so closed inputs are set to nil
+		lc, rc := left, right
+		for lc != nil || rc != nil {
+			select {
+			case <-ctx.Done():
+				drainOpen(lc)
+				drainOpen(rc)
+				return
+			case now := <-expire.C():
+				expire.fired()
+				j.expire(now)
+			case l, open := <-lc:
+				if !open {
+					lc = nil
+					continue
+				}
+				c.metrics.In(c.name)
+				if !j.addLeft(ctx, l, leftKey(l), out) {
+					drainOpen(lc)
+					drainOpen(rc)
+					return
+				}
+			case r, open := <-rc:
+				if !open {
+					rc = nil
+					continue
+				}
+				c.metrics.In(c.name)
+				if !j.addRight(ctx, r, rightKey(r), out) {
+					drainOpen(lc)
+					drainOpen(rc)
+					return
+				}
+			}
+			// Make sure the alarm fires when the oldest entry expires
+			if next, ok := j.nextExpiry(); ok {
+				expire.set(next)
+			}
+		}
+	})
+	return out
+}
+
+// joinEntry is an item held by a keyedJoin
+type joinEntry[L, R any, K comparable] struct {
+	key     K
+	expires time.Time
+	isLeft  bool
+	matched bool
+	left    L
+	right   R
+}
+
+// keyedJoin holds the state of JoinByKey.
+// Entries are kept in arrival order, so the oldest entry of the join and of every key is always first.
+type keyedJoin[L, R any, K comparable] struct {
+	config    *config
+	window    time.Duration
+	maxSize   int
+	unmatched func(L)
+	entries   *list.List
+	lefts     map[K][]*list.Element
+	rights    map[K][]*list.Element
+}
+
+// addLeft sends a pair for every right item held with the same key, then holds the left item.
+// The entries that expired are removed first, since the alarm may not have been received yet.
+// It returns false if the context was canceled before all pairs were sent.
+func (j *keyedJoin[L, R, K]) addLeft(ctx context.Context, l L, k K, out chan<- Pair[L, R]) bool {
+	j.expire(j.config.clock.Now())
+	e := &joinEntry[L, R, K]{key: k, isLeft: true, left: l}
+	for _, el := range j.rights[k] {
+		if !send(ctx, j.config.node, Pair[L, R]{l, el.Value.(*joinEntry[L, R, K]).right}, out) {
+			j.unmatchedLeft(e)
+			return false
+		}
+		j.config.metrics.Out(j.config.name)
+		e.matched = true
+	}
+	j.lefts[k] = append(j.lefts[k], j.add(e))
+	return true
+}
+
+// addRight sends a pair for every left item held with the same key, then holds the right item.
+// The entries that expired are removed first, since the alarm may not have been received yet.
+// It returns false if the context was canceled before all pairs were sent.
+func (j *keyedJoin[L, R, K]) addRight(ctx context.Context, r R, k K, out chan<- Pair[L, R]) bool {
+	j.expire(j.config.clock.Now())
+	for _, el := range j.lefts[k] {
+		e := el.Value.(*joinEntry[L, R, K])
+		if !send(ctx, j.config.node, Pair[L, R]{e.left, r}, out) {
+			return false
+		}
+		j.config.metrics.Out(j.config.name)
+		e.matched = true
+	}
+	j.rights[k] = append(j.rights[k], j.add(&joinEntry[L, R, K]{key: k, right: r}))
+	return true
+}
+
+// add holds an entry, evicting the oldest entries if the join is full
+func (j *keyedJoin[L, R, K]) add(e *joinEntry[L, R, K]) *list.Element {
+	for j.entries.Len() > 0 && j.entries.Len() >= j.maxSize {
+		j.remove(j.entries.Front())
+	}
+	e.expires = j.config.clock.Now().Add(j.window)
+	j.config.node.hold(1)
+	return j.entries.PushBack(e)
+}
+
+// expire removes every entry that expired before now
+func (j *keyedJoin[L, R, K]) expire(now time.Time) {
+	for el := j.entries.Front(); el != nil && !el.Value.(*joinEntry[L, R, K]).expires.After(now); el = j.entries.Front() {
+		j.remove(el)
+	}
+}
+
+// flush removes every entry
+func (j *keyedJoin[L, R, K]) flush() {
+	for el := j.entries.Front(); el != nil; el = j.entries.Front() {
+		j.remove(el)
+	}
+}
+
+// nextExpiry returns the time the oldest entry expires
+func (j *keyedJoin[L, R, K]) nextExpiry() (time.Time, bool) {
+	if el := j.entries.Front(); el != nil {
+		return el.Value.(*joinEntry[L, R, K]).expires, true
+	}
+	return time.Time{}, false
+}
+
+// remove removes the oldest entry, passing it to unmatched if it is an unmatched left item
+func (j *keyedJoin[L, R, K]) remove(el *list.Element) {
+	e := j.entries.Remove(el).(*joinEntry[L, R, K])
+	j.config.node.hold(-1)
+	held := j.rights
+	if e.isLeft {
+		held = j.lefts
+		j.unmatchedLeft(e)
+	}
+	// The oldest entry is always the first entry of its key
+	if els := held[e.key]; len(els) > 1 {
+		held[e.key] = els[1:]
+	} else {
+		delete(held, e.key)
+	}
+}
+
+// unmatchedLeft passes e to the unmatched func if it was never matched
+func (j *keyedJoin[L, R, K]) unmatchedLeft(e *joinEntry[L, R, K]) {
+	if !e.matched && j.unmatched != nil {
+		j.unmatched(e.left)
+	}
+}
+
+// send sends i to out unless the context is canceled first, recording that the stage is blocked until it does.
+// It returns false if the context was canceled.
+func send[Item any](ctx context.Context, n *node, i Item, out chan<- Item) bool {
+	// Prefer the cancellation if out is also ready
+	if isDone(ctx) {
+		return false
+	}
+	n.blockedOnSend(1)
+	defer n.blockedOnSend(-1)
+	select {
+	case <-ctx.Done():
+		return false
+	case out <- i:
+		return true
+	}
+}
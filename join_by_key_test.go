@@ -0,0 +1,341 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline/v2/pipelinetest"
+)
+
+func TestJoinByKey(t *testing.T) {
+	t.Parallel()
+
+	const maxTestDuration = time.Second
+	type order struct {
+		id int
+	}
+	type rider struct {
+		orderID int
+		name    string
+	}
+	// step sends an order or a rider after waiting
+	type step struct {
+		wait  time.Duration
+		order *order
+		rider *rider
+	}
+	type args struct {
+		steps      []step
+		window     time.Duration
+		maxSize    int
+		outer      bool
+		closeIns   bool
+		ctxTimeout time.Duration
+	}
+	type want struct {
+		out       []Pair[order, rider]
+		unmatched []order
+		open      bool
+	}
+	for _, test := range []struct {
+		name string
+		args args
+		want want
+	}{{
+		name: "joins items with the same key in either order",
+		args: args{
+			steps: []step{
+				{order: &order{1}},
+				{order: &order{2}},
+				{rider: &rider{2, "bob"}},
+				{rider: &rider{3, "eve"}},
+				{order: &order{3}},
+				{rider: &rider{1, "ann"}},
+			},
+			window:     maxTestDuration,
+			maxSize:    10,
+			outer:      true,
+			closeIns:   true,
+			ctxTimeout: maxTestDuration,
+		},
+		want: want{
+			out: []Pair[order, rider]{
+				{order{2}, rider{2, "bob"}},
+				{order{3}, rider{3, "eve"}},
+				{order{1}, rider{1, "ann"}},
+			},
+			unmatched: nil,
+			open:      false,
+		},
+	}, {
+		name: "items are not joined after the window expires",
+		args: args{
+			steps: []step{
+				{order: &order{1}},
+				{order: &order{2}},
+				{wait: maxTestDuration / 4, rider: &rider{1, "ann"}},
+				{rider: &rider{2, "bob"}},
+			},
+			window:     maxTestDuration / 10,
+			maxSize:    10,
+			outer:      true,
+			closeIns:   true,
+			ctxTimeout: maxTestDuration,
+		},
+		want: want{
+			out:       nil,
+			unmatched: []order{{1}, {2}},
+			open:      false,
+		},
+	}, {
+		name: "the oldest items are evicted when the join is full",
+		args: args{
+			steps: []step{
+				{order: &order{1}},
+				{order: &order{2}},
+				{order: &order{3}},
+				{rider: &rider{1, "ann"}},
+				{rider: &rider{3, "eve"}},
+			},
+			window:     maxTestDuration,
+			maxSize:    2,
+			outer:      true,
+			closeIns:   true,
+			ctxTimeout: maxTestDuration,
+		},
+		want: want{
+			out: []Pair[order, rider]{
+				{order{3}, rider{3, "eve"}},
+			},
+			unmatched: []order{{1}, {2}},
+			open:      false,
+		},
+	}, {
+		name: "inner joins drop unmatched items",
+		args: args{
+			steps: []step{
+				{order: &order{1}},
+				{order: &order{2}},
+				{rider: &rider{2, "bob"}},
+			},
+			window:     maxTestDuration,
+			maxSize:    10,
+			outer:      false,
+			closeIns:   true,
+			ctxTimeout: maxTestDuration,
+		},
+		want: want{
+			out: []Pair[order, rider]{
+				{order{2}, rider{2, "bob"}},
+			},
+			unmatched: nil,
+			open:      false,
+		},
+	}, {
+		name: "out stays open as long as the inputs are open",
+		args: args{
+			steps: []step{
+				{order: &order{1}},
+				{rider: &rider{1, "ann"}},
+			},
+			window:     maxTestDuration,
+			maxSize:    10,
+			outer:      true,
+			closeIns:   false,
+			ctxTimeout: 2 * maxTestDuration,
+		},
+		want: want{
+			out: []Pair[order, rider]{
+				{order{1}, rider{1, "ann"}},
+			},
+			unmatched: nil,
+			open:      true,
+		},
+	}, {
+		name: "unmatched items are flushed when the context is canceled",
+		args: args{
+			steps: []step{
+				{order: &order{1}},
+				{order: &order{2}},
+			},
+			window:     2 * maxTestDuration,
+			maxSize:    10,
+			outer:      true,
+			closeIns:   false,
+			ctxTimeout: maxTestDuration / 2,
+		},
+		want: want{
+			out:       nil,
+			unmatched: []order{{1}, {2}},
+			open:      false,
+		},
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithTimeout(context.Background(), test.args.ctxTimeout)
+			defer cancel()
+
+			// Send orders and riders in the order of the test
+			orders, riders := make(chan order), make(chan rider)
+			go func() {
+				for _, s := range test.args.steps {
+					time.Sleep(s.wait)
+					if s.order != nil {
+						orders <- *s.order
+					} else {
+						riders <- *s.rider
+					}
+				}
+				if test.args.closeIns {
+					close(orders)
+					close(riders)
+				}
+			}()
+
+			// Collect unmatched orders
+			var mu sync.Mutex
+			var unmatched []order
+			opts := []Option{WithMaxSize(test.args.maxSize)}
+			if test.args.outer {
+				opts = append(opts, WithUnmatched(func(o order) {
+					mu.Lock()
+					defer mu.Unlock()
+					unmatched = append(unmatched, o)
+				}))
+			}
+
+			joined := JoinByKey(ctx, orders, riders,
+				func(o order) int { return o.id },
+				func(r rider) int { return r.orderID },
+				test.args.window,
+				opts...,
+			)
+			timeout := time.After(maxTestDuration)
+			var outs []Pair[order, rider]
+			var isOpen bool
+		loop:
+			for {
+				select {
+				case o, open := <-joined:
+					isOpen = open
+					if !open {
+						break loop
+					}
+					outs = append(outs, o)
+				case <-timeout:
+					break loop
+				}
+			}
+
+			if test.want.open != isOpen {
+				t.Errorf("open = %t, want %t", isOpen, test.want.open)
+			}
+			if !reflect.DeepEqual(test.want.out, outs) {
+				t.Errorf("out = %+v, want %+v", outs, test.want.out)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if !reflect.DeepEqual(test.want.unmatched, unmatched) {
+				t.Errorf("unmatched = %+v, want %+v", unmatched, test.want.unmatched)
+			}
+		})
+	}
+}
+
+func TestJoinByKeyUnmatchedOfAnotherType(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("JoinByKey didn't panic")
+		}
+	}()
+	// The unmatched left items are ints, not strings
+	JoinByKey(context.Background(), make(chan int), make(chan int),
+		func(i int) int { return i },
+		func(i int) int { return i },
+		time.Second,
+		WithUnmatched(func(string) {}),
+	)
+}
+
+func TestJoinByKeyOptionsOfOtherStages(t *testing.T) {
+	t.Parallel()
+
+	for name, opt := range map[string]Option{
+		"WithMaxSize":   WithMaxSize(1),
+		"WithUnmatched": WithUnmatched(func(int) {}),
+	} {
+		name, opt := name, opt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Process didn't panic when given %s", name)
+				}
+			}()
+			Process[int, int](context.Background(), &mockProcessor[int]{}, make(chan int), opt)
+		})
+	}
+}
+
+func TestJoinByKeyHeld(t *testing.T) {
+	t.Parallel()
+
+	g := NewGraph()
+	clock := pipelinetest.NewFakeClock(time.Now())
+	left, right := make(chan int), make(chan int)
+	out := JoinByKey(context.Background(), left, right, func(l int) int { return l }, func(r int) int { return r }, time.Minute, WithGraph(g), WithClock(clock))
+
+	// Every item is held until it expires, matched or not
+	left <- 1
+	right <- 1
+	if got := <-out; got != (Pair[int, int]{1, 1}) {
+		t.Errorf("got %v, want {1 1}", got)
+	}
+	left <- 2
+	waitFor(t, func() bool { return g.Status()[0].Held == 3 })
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	waitFor(t, func() bool { return g.Status()[0].Held == 0 })
+	close(left)
+	close(right)
+	Drain(out)
+}
+
+func TestJoinByKeyExpiresBeforeMatching(t *testing.T) {
+	t.Parallel()
+
+	// The alarm and the next input are ready at once, and select would pick either of them at random
+	for n := 0; n < 20; n++ {
+		g := NewGraph()
+		clock := pipelinetest.NewFakeClock(time.Now())
+		left, right := make(chan string), make(chan string, 1)
+		key := func(s string) byte { return s[0] }
+		out := JoinByKey(context.Background(), left, right, key, key, time.Minute, WithGraph(g), WithClock(clock))
+
+		// b0 is held while the join is blocked sending the match of a1
+		left <- "b0"
+		left <- "a1"
+		right <- "a1"
+		waitFor(t, func() bool { return g.Status()[0].BlockedOnSend == 1 })
+		clock.Advance(2 * time.Minute)
+		right <- "b2"
+		if got := <-out; got != (Pair[string, string]{"a1", "a1"}) {
+			t.Errorf("got %v, want {a1 a1}", got)
+		}
+
+		// b2 arrived after b0 expired, so they don't match
+		close(left)
+		close(right)
+		if got := pipelinetest.Collect(t, out, time.Second); len(got) != 0 {
+			t.Fatalf("got %v, want no more pairs", got)
+		}
+	}
+}
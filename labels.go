@@ -0,0 +1,22 @@
+package pipeline
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// The goroutines of every stage are labelled with the name and kind of the stage,
+// so they can be told apart in goroutine and CPU profiles, e.g. with `go tool pprof -tagfocus pipeline.stage=process`.
+// Stages that can't be configured with options, like Merge, are named after their kind.
+const (
+	StageLabel = "pipeline.stage"
+	KindLabel  = "pipeline.kind"
+)
+
+// goStage runs f in a new goroutine labelled with the name and kind of a stage.
+// It keeps the labels already set on ctx, and the goroutines f starts inherit all of them.
+func goStage(ctx context.Context, name, kind string, f func()) {
+	go pprof.Do(ctx, pprof.Labels(StageLabel, name, KindLabel, kind), func(context.Context) {
+		f()
+	})
+}
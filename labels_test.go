@@ -0,0 +1,62 @@
+package pipeline
+
+import (
+	"context"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// labelledGoroutines counts the goroutines by the labels in the goroutine profile
+func labelledGoroutines(t *testing.T) map[string]int {
+	t.Helper()
+	var b strings.Builder
+	if err := pprof.Lookup("goroutine").WriteTo(&b, 1); err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	lines := strings.Split(b.String(), "\n")
+	for i := 1; i < len(lines); i++ {
+		labels, ok := strings.CutPrefix(lines[i], "# labels: ")
+		if !ok {
+			continue
+		}
+		n, _, _ := strings.Cut(lines[i-1], " @ ")
+		count, _ := strconv.Atoi(n)
+		counts[labels] += count
+	}
+	return counts
+}
+
+func TestStageLabels(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(pprof.WithLabels(context.Background(), pprof.Labels("app", "test")))
+	defer cancel()
+
+	// Block the stages by never reading from them
+	blocked := make(chan int)
+	defer close(blocked)
+	Merge(Emit(1), Emit(2), blocked)
+	ProcessConcurrently(ctx, 2, NewProcessor(func(ctx context.Context, i int) (int, error) {
+		<-ctx.Done()
+		return i, ctx.Err()
+	}, func(int, error) {}), Emit(1, 2, 3), WithName("TestStageLabels"))
+
+	want := map[string]int{
+		// Labels of ctx are kept, and the goroutines processing each input inherit the labels
+		`{"app":"test", "pipeline.kind":"ProcessConcurrently", "pipeline.stage":"TestStageLabels"}`: 3,
+		// One goroutine for each in, and one to close the out chan
+		`{"pipeline.kind":"Merge", "pipeline.stage":"Merge"}`: 4,
+	}
+	waitFor(t, func() bool {
+		got := labelledGoroutines(t)
+		for labels, n := range want {
+			if got[labels] < n {
+				return false
+			}
+		}
+		return true
+	})
+}
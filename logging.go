@@ -0,0 +1,161 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/deliveryhero/pipeline/v2/internal/clock"
+)
+
+// Logging is sampled so mass failure doesn't flood the logs:
+// at most logBurst failures and cancellations are logged every logInterval.
+// The number of records that were left out is added to the next one.
+const (
+	logInterval = time.Second
+	logBurst    = 10
+)
+
+// WithLogging wraps a Processor so that it logs every input it cancels.
+// Inputs canceled because the context is canceled are logged at the Info level, inputs that failed at the Error level.
+func WithLogging[Input, Output any](p Processor[Input, Output], logger *slog.Logger) Processor[Input, Output] {
+	return &loggingProcessor[Input, Output]{
+		processor: p,
+		logger:    logger,
+		sampler:   newSampler(clock.Real(), logInterval, logBurst),
+	}
+}
+
+type loggingProcessor[Input, Output any] struct {
+	processor Processor[Input, Output]
+	logger    *slog.Logger
+	sampler   *sampler
+}
+
+func (p *loggingProcessor[Input, Output]) Process(ctx context.Context, i Input) (Output, error) {
+	return p.processor.Process(ctx, i)
+}
+
+func (p *loggingProcessor[Input, Output]) Cancel(i Input, err error) {
+	logCancel(p.logger, p.sampler, err, slog.Any("input", i))
+	p.processor.Cancel(i, err)
+}
+
+// WithLogger makes a stage log the inputs it cancels, when it starts shutting down and when it closes
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *config) {
+		// The sampler tells the time with the clock of the stage, once it is configured
+		c.logger = &stageLogger{logger: logger}
+	}
+}
+
+// stageLogger logs what a stage configured WithLogger does. It observes the stage by implementing Metrics.
+type stageLogger struct {
+	NoopMetrics
+	logger   *slog.Logger
+	sampler  *sampler
+	shutdown sync.Once
+
+	in       int64
+	out      int64
+	canceled int64
+	dropped  int64
+}
+
+func (l *stageLogger) In(string) {
+	atomic.AddInt64(&l.in, 1)
+}
+
+func (l *stageLogger) Out(string) {
+	atomic.AddInt64(&l.out, 1)
+}
+
+func (l *stageLogger) Drop(string) {
+	atomic.AddInt64(&l.dropped, 1)
+}
+
+func (l *stageLogger) Cancel(stage string, err error) {
+	atomic.AddInt64(&l.canceled, 1)
+	if isContextErr(err) {
+		l.shutdown.Do(func() {
+			l.logger.Info("stage shutting down", slog.String("stage", stage), slog.Any("error", err))
+		})
+	}
+	logCancel(l.logger, l.sampler, err, slog.String("stage", stage))
+}
+
+// closed logs that the stage closed its out channel, with the number of items that passed through it
+func (l *stageLogger) closed(stage string) {
+	l.logger.Info("stage closed",
+		slog.String("stage", stage),
+		slog.Int64("in", atomic.LoadInt64(&l.in)),
+		slog.Int64("out", atomic.LoadInt64(&l.out)),
+		slog.Int64("canceled", atomic.LoadInt64(&l.canceled)),
+		slog.Int64("dropped", atomic.LoadInt64(&l.dropped)),
+	)
+}
+
+// closed logs that the stage closed, if it is configured WithLogger
+func (c *config) closed() {
+	if c.logger != nil {
+		c.logger.closed(c.name)
+	}
+}
+
+// logCancel logs that an input was canceled with err, unless the sampler leaves it out
+func logCancel(logger *slog.Logger, s *sampler, err error, attrs ...slog.Attr) {
+	ok, suppressed := s.allow()
+	if !ok {
+		return
+	}
+	attrs = append(attrs, slog.Any("error", err))
+	if suppressed > 0 {
+		attrs = append(attrs, slog.Int("suppressed", suppressed))
+	}
+	if isContextErr(err) {
+		logger.LogAttrs(context.Background(), slog.LevelInfo, "input canceled", attrs...)
+		return
+	}
+	logger.LogAttrs(context.Background(), slog.LevelError, "input failed", attrs...)
+}
+
+// isContextErr returns true if err is from a context being canceled
+func isContextErr(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// sampler allows up to burst records every interval
+type sampler struct {
+	mu         sync.Mutex
+	clock      Clock
+	interval   time.Duration
+	burst      int
+	start      time.Time
+	allowed    int
+	suppressed int
+}
+
+func newSampler(clock Clock, interval time.Duration, burst int) *sampler {
+	return &sampler{clock: clock, interval: interval, burst: burst}
+}
+
+// allow returns whether a record is allowed,
+// and when it is, how many records were suppressed since the last one that was
+func (s *sampler) allow() (bool, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now := s.clock.Now(); now.Sub(s.start) >= s.interval {
+		s.start, s.allowed = now, 0
+	}
+	if s.allowed >= s.burst {
+		s.suppressed++
+		return false, 0
+	}
+	s.allowed++
+	suppressed := s.suppressed
+	s.suppressed = 0
+	return true, suppressed
+}
@@ -0,0 +1,117 @@
+package pipeline
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline/v2/pipelinetest"
+)
+
+// recordingHandler is a slog.Handler that records the messages and attributes it handles
+type recordingHandler struct {
+	mu      sync.Mutex
+	records []record
+}
+
+type record struct {
+	level slog.Level
+	msg   string
+	attrs map[string]any
+}
+
+func (h *recordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *recordingHandler) Handle(_ context.Context, r slog.Record) error {
+	rec := record{level: r.Level, msg: r.Message, attrs: make(map[string]any)}
+	r.Attrs(func(a slog.Attr) bool {
+		rec.attrs[a.Key] = a.Value.Any()
+		return true
+	})
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, rec)
+	return nil
+}
+
+func (h *recordingHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h *recordingHandler) WithGroup(string) slog.Handler { return h }
+
+// messages counts the records by message
+func (h *recordingHandler) messages() map[string]int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	msgs := make(map[string]int)
+	for _, r := range h.records {
+		msgs[r.msg]++
+	}
+	return msgs
+}
+
+func TestWithLogging(t *testing.T) {
+	t.Parallel()
+
+	h := new(recordingHandler)
+	p := WithLogging[int, int](&mockProcessor[int]{processReturnsErrs: true}, slog.New(h))
+	Drain(Process(context.Background(), p, Emit(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12)))
+
+	// Only the first logBurst failures are logged
+	if got := h.messages(); got["input failed"] != logBurst || len(got) != 1 {
+		t.Errorf("messages = %v, want %d input failed", got, logBurst)
+	}
+	first := h.records[0]
+	if first.level != slog.LevelError || first.attrs["input"] != int64(1) || first.attrs["error"] == nil {
+		t.Errorf("first record = %+v", first)
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	h := new(recordingHandler)
+	Drain(Process[int, int](ctx, &mockProcessor[int]{}, Emit(1, 2, 3), WithName("process"), WithLogger(slog.New(h))))
+
+	want := map[string]int{
+		"stage shutting down": 1,
+		"input canceled":      3,
+		"stage closed":        1,
+	}
+	got := h.messages()
+	for msg, n := range want {
+		if got[msg] != n {
+			t.Errorf("%q logged %d times, want %d", msg, got[msg], n)
+		}
+	}
+	canceled := h.records[1]
+	if canceled.level != slog.LevelInfo || canceled.attrs["stage"] != "process" {
+		t.Errorf("canceled record = %+v", canceled)
+	}
+	closed := h.records[len(h.records)-1]
+	if closed.msg != "stage closed" || closed.attrs["in"] != int64(3) || closed.attrs["out"] != int64(0) || closed.attrs["canceled"] != int64(3) {
+		t.Errorf("closed record = %+v", closed)
+	}
+}
+
+func Test_sampler(t *testing.T) {
+	t.Parallel()
+
+	clock := pipelinetest.NewFakeClock(time.Now())
+	s := newSampler(clock, time.Second, 2)
+	for i, want := range []bool{true, true, false, false} {
+		if ok, _ := s.allow(); ok != want {
+			t.Errorf("allow %d = %t, want %t", i, ok, want)
+		}
+	}
+
+	// The next record allowed counts the ones that were suppressed
+	clock.Advance(time.Second)
+	if ok, suppressed := s.allow(); !ok || suppressed != 2 {
+		t.Errorf("allow = %t, %d, want true, 2", ok, suppressed)
+	}
+}
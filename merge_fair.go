@@ -0,0 +1,60 @@
+package pipeline
+
+// MergeFair fans multiple channels in to a single channel like Merge, but takes turns between the channels that have an item ready,
+// so a channel with many items can't starve the others while the out channel is slower than the ins.
+// The out channel is closed after all of the ins are closed and their items are passed on.
+// Like MergePriority, it takes the ins as a slice so it can be configured with options, like WithGraph.
+func MergeFair[Item any](ins []<-chan Item, opts ...Option) <-chan Item {
+	return mergeScheduled("MergeFair", newDeficitScheduler(nil, len(ins)), ins, opts)
+}
+
+// MergeWeighted fans multiple channels in to a single channel like MergeFair, but gives each channel a share of the out channel by its weight.
+// The in at `ins[k]` passes on up to `weights[k]` items in its turn, so while every in has items ready,
+// an in with a weight of 2 passes on twice as many items as an in with a weight of 1.
+// An in without a weight, or with a weight below 1, has a weight of 1.
+// The share of an in that has no item ready is passed on to the others, rather than saved for later, like deficit round robin.
+// The out channel is closed after all of the ins are closed and their items are passed on.
+func MergeWeighted[Item any](weights []int, ins []<-chan Item, opts ...Option) <-chan Item {
+	return mergeScheduled("MergeWeighted", newDeficitScheduler(weights, len(ins)), ins, opts)
+}
+
+// deficitScheduler takes turns between the ins, like deficit round robin where every item costs 1
+type deficitScheduler struct {
+	weights []int
+	// turn is the in whose turn it is, and deficit is how many more items it can pass on in its turn
+	turn    int
+	deficit int
+}
+
+func newDeficitScheduler(weights []int, n int) *deficitScheduler {
+	s := &deficitScheduler{weights: make([]int, n)}
+	for k := range s.weights {
+		s.weights[k] = 1
+		if k < len(weights) && weights[k] > 1 {
+			s.weights[k] = weights[k]
+		}
+	}
+	// The first turn starts when the first item is passed on
+	s.turn = n - 1
+	return s
+}
+
+func (s *deficitScheduler) next(waiting []bool) int {
+	if waiting[s.turn] && s.deficit > 0 {
+		return s.turn
+	}
+	// The turn passes to the next in with an item waiting, skipping the ins that have nothing to pass on
+	for k := 1; k <= len(waiting); k++ {
+		if next := (s.turn + k) % len(waiting); waiting[next] {
+			return next
+		}
+	}
+	return -1
+}
+
+func (s *deficitScheduler) passed(k int) {
+	if k != s.turn || s.deficit <= 0 {
+		s.turn, s.deficit = k, s.weights[k]
+	}
+	s.deficit--
+}
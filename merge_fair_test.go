@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline/v2/pipelinetest"
+)
+
+// ready returns a closed chan holding the items, so they are all ready before anything is read from it
+func ready[Item any](is ...Item) <-chan Item {
+	out := make(chan Item, len(is))
+	for _, i := range is {
+		out <- i
+	}
+	close(out)
+	return out
+}
+
+func TestMergeFair(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		weights []int
+		ins     [][]int
+	}
+	type want struct {
+		out []int
+	}
+	for _, test := range []struct {
+		name string
+		args args
+		want want
+	}{{
+		name: "takes turns between the ins",
+		args: args{
+			ins: [][]int{{1, 2, 3, 4}, {10, 20}, {100}},
+		},
+		want: want{
+			out: []int{1, 10, 100, 2, 20, 3, 4},
+		},
+	}, {
+		name: "passes on items by the weights of the ins",
+		args: args{
+			weights: []int{2, 1},
+			ins:     [][]int{{1, 2, 3, 4, 5}, {10, 20, 30}},
+		},
+		want: want{
+			out: []int{1, 2, 10, 3, 4, 20, 5, 30},
+		},
+	}, {
+		name: "ins without a weight have a weight of 1",
+		args: args{
+			weights: []int{0, 3},
+			ins:     [][]int{{1, 2, 3}, {10, 20, 30, 40}, {100, 200}},
+		},
+		want: want{
+			out: []int{1, 10, 20, 30, 100, 2, 40, 200, 3},
+		},
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var ins []<-chan int
+			for _, is := range test.args.ins {
+				ins = append(ins, ready(is...))
+			}
+			var out <-chan int
+			if test.args.weights == nil {
+				out = MergeFair(ins)
+			} else {
+				out = MergeWeighted(test.args.weights, ins)
+			}
+			if got := pipelinetest.Collect(t, out, time.Second); !reflect.DeepEqual(got, test.want.out) {
+				t.Errorf("out = %v, want %v", got, test.want.out)
+			}
+		})
+	}
+}
+
+func TestMergeFairNilItems(t *testing.T) {
+	t.Parallel()
+
+	// A nil error is an item like any other
+	out := MergeFair([]<-chan error{ready[error](nil), ready[error](nil)})
+	if errs := pipelinetest.Collect(t, out, time.Second); len(errs) != 2 {
+		t.Errorf("out = %v, want 2 nil errors", errs)
+	}
+}
+
+func TestMergeWeightedWithGraph(t *testing.T) {
+	t.Parallel()
+
+	g := NewGraph()
+	out := MergeWeighted([]int{2, 1}, []<-chan int{ready(1, 2, 3), ready(10)}, WithName("weighted"), WithGraph(g))
+
+	// The ins are connected to the stage, which counts what it passes on
+	if got := pipelinetest.Collect(t, out, time.Second); len(got) != 4 {
+		t.Errorf("out = %v, want 4 items", got)
+	}
+	waitFor(t, func() bool {
+		s := g.Status()[0]
+		return s.Name == "weighted" && s.Kind == "MergeWeighted" && s.In == 4 && s.Out == 4 && s.Held == 0
+	})
+}
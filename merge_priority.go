@@ -0,0 +1,24 @@
+package pipeline
+
+// MergePriority fans multiple channels in to a single channel like Merge, but prefers the channels that come first in `ins`.
+// Whenever the out channel is ready, it receives an item from the first channel that has one ready, instead of one chosen at random,
+// so items from a channel later in `ins` are only passed on while the channels before it have nothing to pass on.
+// The out channel is closed after all of the ins are closed and their items are passed on.
+// Unlike Merge, it takes the ins as a slice so it can be configured with options, like WithGraph.
+func MergePriority[Item any](ins []<-chan Item, opts ...Option) <-chan Item {
+	return mergeScheduled("MergePriority", priorityScheduler{}, ins, opts)
+}
+
+// priorityScheduler always picks the first in that has an item waiting
+type priorityScheduler struct{}
+
+func (priorityScheduler) next(waiting []bool) int {
+	for k, w := range waiting {
+		if w {
+			return k
+		}
+	}
+	return -1
+}
+
+func (priorityScheduler) passed(int) {}
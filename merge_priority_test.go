@@ -0,0 +1,60 @@
+package pipeline
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline/v2/pipelinetest"
+)
+
+func TestMergePriority(t *testing.T) {
+	t.Parallel()
+
+	// Every in has items ready before anything is read from the out channel
+	high, low := make(chan int, 3), make(chan int, 3)
+	for i := 1; i <= 3; i++ {
+		high <- i
+		low <- i * 10
+	}
+	close(high)
+	close(low)
+	out := MergePriority([]<-chan int{high, low})
+
+	// The items of low are passed on once high is closed
+	got := pipelinetest.Collect(t, out, time.Second)
+	if want := []int{1, 2, 3, 10, 20, 30}; !reflect.DeepEqual(got, want) {
+		t.Errorf("out = %v, want %v", got, want)
+	}
+}
+
+func TestMergePriorityNilInterface(t *testing.T) {
+	t.Parallel()
+
+	// A nil error is passed on like any other error
+	failed := errors.New("failed")
+	out := MergePriority([]<-chan error{ready[error](nil, failed), ready[error](nil)})
+
+	got := pipelinetest.Collect(t, out, time.Second)
+	if want := []error{nil, failed, nil}; !reflect.DeepEqual(got, want) {
+		t.Errorf("out = %v, want %v", got, want)
+	}
+}
+
+func TestMergePriorityWithGraph(t *testing.T) {
+	t.Parallel()
+
+	g := NewGraph()
+	high, low := ready(1, 2), ready(10)
+	out := MergePriority([]<-chan int{high, low}, WithName("priority"), WithGraph(g))
+
+	// The ins are connected to the stage, which counts what it passes on
+	if got := pipelinetest.Collect(t, out, time.Second); !reflect.DeepEqual(got, []int{1, 2, 10}) {
+		t.Errorf("out = %v, want [1 2 10]", got)
+	}
+	waitFor(t, func() bool {
+		s := g.Status()[0]
+		return s.Name == "priority" && s.Kind == "MergePriority" && s.In == 3 && s.Out == 3 && s.Held == 0
+	})
+}
@@ -0,0 +1,110 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+)
+
+// scheduler decides which of the ins of a merge passes on the next item, see mergeScheduled
+type scheduler interface {
+	// next returns the in whose item is passed on next, out of the ins that have an item waiting.
+	// It is called again whenever another in has an item waiting, until the item is passed on.
+	next(waiting []bool) int
+	// passed records that the item of in k was passed on
+	passed(k int)
+}
+
+// mergeScheduled fans multiple channels in to a single channel, passing on the items of the ins in the order picked by `s` instead of at random.
+// Items are passed on as they are, including nil interface values.
+// The items received from the ins and not passed on yet are held by the stage.
+func mergeScheduled[Item any](kind string, s scheduler, ins []<-chan Item, opts []Option) <-chan Item {
+	c := newConfig(kind, opts)
+	out := make(chan Item)
+	// The ins that are closed are set to nil, without changing the slice of the caller
+	ins = append([]<-chan Item(nil), ins...)
+	recv := make([]any, len(ins))
+	for k, in := range ins {
+		recv[k] = in
+	}
+	connect(c, out, recv...)
+	goStage(context.Background(), c.name, c.kind, func() {
+		defer c.closed()
+		defer close(out)
+		// Each in has at most one item waiting to be passed on, and nothing more is received from it until that item is passed on
+		items := make([]Item, len(ins))
+		waiting := make([]bool, len(ins))
+		open := len(ins)
+		// take records the result of receiving from one of the ins
+		take := func(k int, i Item, ok bool) {
+			if !ok {
+				ins[k] = nil
+				open--
+				return
+			}
+			c.metrics.In(c.name)
+			c.node.hold(1)
+			items[k], waiting[k] = i, true
+		}
+		cases := make([]reflect.SelectCase, 0, len(ins)+1)
+		ks := make([]int, 0, len(ins))
+		for {
+			// Receive the items that are ready, so the item that is passed on is never chosen at random
+			next := -1
+			for k, in := range ins {
+				if in != nil && !waiting[k] {
+					select {
+					case i, ok := <-in:
+						take(k, i, ok)
+					default:
+					}
+				}
+				if waiting[k] {
+					next = k
+				}
+			}
+			if next == -1 && open == 0 {
+				return
+			}
+
+			// Wait until the next item is passed on, or another in is ready
+			cases, ks = cases[:0], ks[:0]
+			if next != -1 {
+				next = s.next(waiting)
+				// The element of the slice keeps the type of a nil interface value, which reflect.ValueOf(items[next]) would lose
+				cases = append(cases, reflect.SelectCase{Dir: reflect.SelectSend, Chan: reflect.ValueOf(out), Send: reflect.ValueOf(&items[next]).Elem()})
+				ks = append(ks, -1)
+			}
+			for k, in := range ins {
+				if in != nil && !waiting[k] {
+					cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(in)})
+					ks = append(ks, k)
+				}
+			}
+			chosen, v, ok := selectBlocked(c.node, next != -1, cases)
+			if k := ks[chosen]; k == -1 {
+				var zero Item
+				items[next], waiting[next] = zero, false
+				c.node.hold(-1)
+				s.passed(next)
+				c.metrics.Out(c.name)
+			} else {
+				// The value of a closed in or a nil interface is not an Item
+				i, _ := v.Interface().(Item)
+				take(k, i, ok)
+			}
+		}
+	})
+	return out
+}
+
+// selectBlocked runs reflect.Select, recording that the stage is blocked on sending if it has an item to send, or on receiving otherwise
+func selectBlocked(n *node, sending bool, cases []reflect.SelectCase) (int, reflect.Value, bool) {
+	if sending {
+		n.blockedOnSend(1)
+		defer n.blockedOnSend(-1)
+	} else {
+		n.blockedOnReceive(1)
+		defer n.blockedOnReceive(-1)
+	}
+	return reflect.Select(cases)
+}
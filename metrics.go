@@ -0,0 +1,130 @@
+package pipeline
+
+import "time"
+
+// Metrics observes the items passing through a stage.
+// Each method is called with the name of the stage, see WithName.
+// Implementations must be safe for concurrent use. Embed NoopMetrics to implement only some of the methods.
+type Metrics interface {
+	// In is called when the stage receives an item from its in channel.
+	In(stage string)
+
+	// Out is called when the stage sends an item to its out channel.
+	Out(stage string)
+
+	// Process is called after `Processor.Process` returns, with how long it took and the error it returned.
+	Process(stage string, duration time.Duration, err error)
+
+	// Cancel is called when `Processor.Cancel` is called, with the error it was called with.
+	Cancel(stage string, err error)
+
+	// InFlight is called with the number of inputs the stage starts processing,
+	// and with the negative of that number once they have been sent to the out channel or canceled.
+	InFlight(stage string, delta int)
+
+	// Batch is called with the size of every batch the stage collects.
+	Batch(stage string, size int)
+
+	// QueueWait is called with how long an input, or the oldest input of a batch,
+	// waited after it was received before it was processed.
+	QueueWait(stage string, duration time.Duration)
+}
+
+// DropMetrics is implemented by the Metrics that count the items stages drop.
+// Stages report to it if their Metrics implement it, so Metrics that don't keep working.
+type DropMetrics interface {
+	// Drop is called when the stage drops an item, like BufferWithPolicy when its buffer is full.
+	Drop(stage string)
+}
+
+// reportDrop reports a dropped item to `m` if it implements DropMetrics
+func reportDrop(m Metrics, stage string) {
+	if d, ok := m.(DropMetrics); ok {
+		d.Drop(stage)
+	}
+}
+
+// WithMetrics makes a stage report to `m`
+func WithMetrics(m Metrics) Option {
+	return func(c *config) {
+		c.metrics = m
+	}
+}
+
+// NoopMetrics is a Metrics that does nothing. It is used by stages that are not configured WithMetrics.
+type NoopMetrics struct{}
+
+// In does nothing
+func (NoopMetrics) In(string) {}
+
+// Out does nothing
+func (NoopMetrics) Out(string) {}
+
+// Process does nothing
+func (NoopMetrics) Process(string, time.Duration, error) {}
+
+// Cancel does nothing
+func (NoopMetrics) Cancel(string, error) {}
+
+// InFlight does nothing
+func (NoopMetrics) InFlight(string, int) {}
+
+// Batch does nothing
+func (NoopMetrics) Batch(string, int) {}
+
+// QueueWait does nothing
+func (NoopMetrics) QueueWait(string, time.Duration) {}
+
+// Drop does nothing
+func (NoopMetrics) Drop(string) {}
+
+// multiMetrics reports to many Metrics
+type multiMetrics []Metrics
+
+func (ms multiMetrics) In(stage string) {
+	for _, m := range ms {
+		m.In(stage)
+	}
+}
+
+func (ms multiMetrics) Out(stage string) {
+	for _, m := range ms {
+		m.Out(stage)
+	}
+}
+
+func (ms multiMetrics) Process(stage string, duration time.Duration, err error) {
+	for _, m := range ms {
+		m.Process(stage, duration, err)
+	}
+}
+
+func (ms multiMetrics) Cancel(stage string, err error) {
+	for _, m := range ms {
+		m.Cancel(stage, err)
+	}
+}
+
+func (ms multiMetrics) InFlight(stage string, delta int) {
+	for _, m := range ms {
+		m.InFlight(stage, delta)
+	}
+}
+
+func (ms multiMetrics) Batch(stage string, size int) {
+	for _, m := range ms {
+		m.Batch(stage, size)
+	}
+}
+
+func (ms multiMetrics) QueueWait(stage string, duration time.Duration) {
+	for _, m := range ms {
+		m.QueueWait(stage, duration)
+	}
+}
+
+func (ms multiMetrics) Drop(stage string) {
+	for _, m := range ms {
+		reportDrop(m, stage)
+	}
+}
@@ -0,0 +1,138 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	// Fail to process the number 2
+	double := NewProcessor(func(_ context.Context, i int) (int, error) {
+		if i == 2 {
+			return 0, errors.New("2 is not allowed")
+		}
+		return i * 2, nil
+	}, func(int, error) {})
+	sum := NewProcessor(func(_ context.Context, is []int) ([]int, error) {
+		var s int
+		for _, i := range is {
+			s += i
+		}
+		return []int{s}, nil
+	}, func([]int, error) {})
+
+	type want struct {
+		in         int
+		out        int
+		processed  int
+		errs       int
+		canceled   int
+		batches    []int
+		queueWaits int
+	}
+	for _, test := range []struct {
+		name  string
+		stage func(m Metrics) <-chan int
+		want  want
+	}{{
+		name: "Process",
+		stage: func(m Metrics) <-chan int {
+			return Process(context.Background(), double, Emit(1, 2, 3), WithName("Process"), WithMetrics(m))
+		},
+		want: want{in: 3, out: 2, processed: 3, errs: 1, canceled: 1, queueWaits: 3},
+	}, {
+		name: "ProcessConcurrently",
+		stage: func(m Metrics) <-chan int {
+			return ProcessConcurrently(context.Background(), 2, double, Emit(1, 2, 3), WithName("ProcessConcurrently"), WithMetrics(m))
+		},
+		want: want{in: 3, out: 2, processed: 3, errs: 1, canceled: 1, queueWaits: 3},
+	}, {
+		name: "ProcessBatch",
+		stage: func(m Metrics) <-chan int {
+			return ProcessBatch(context.Background(), 2, time.Minute, sum, Emit(1, 2, 3), WithName("ProcessBatch"), WithMetrics(m))
+		},
+		want: want{in: 3, out: 2, processed: 2, batches: []int{2, 1}, queueWaits: 2},
+	}, {
+		name: "Collect",
+		stage: func(m Metrics) <-chan int {
+			return Split(Collect(context.Background(), 2, time.Minute, Emit(1, 2, 3), WithName("Collect"), WithMetrics(m)))
+		},
+		want: want{in: 3, out: 2, batches: []int{2, 1}, queueWaits: 2},
+	}, {
+		name: "Zip",
+		stage: func(m Metrics) <-chan int {
+			add := NewProcessor(func(_ context.Context, p Pair[int, int]) (int, error) {
+				return p.First + p.Second, nil
+			}, func(Pair[int, int], error) {})
+			return Process(context.Background(), add, Zip(context.Background(), Emit(1, 2), Emit(3, 4), WithName("Zip"), WithMetrics(m)))
+		},
+		want: want{in: 4, out: 2},
+	}, {
+		name: "Distinct",
+		stage: func(m Metrics) <-chan int {
+			return Distinct(context.Background(), func(i int) int { return i }, time.Minute, Emit(1, 2, 1), WithName("Distinct"), WithMetrics(m))
+		},
+		want: want{in: 3, out: 2},
+	}, {
+		name: "Take",
+		stage: func(m Metrics) <-chan int {
+			return Take(context.Background(), 2, Emit(1, 2, 3), WithName("Take"), WithMetrics(m))
+		},
+		// Take is done once it has taken the last item, without receiving the one after it
+		want: want{in: 2, out: 2},
+	}, {
+		name: "Skip",
+		stage: func(m Metrics) <-chan int {
+			return Skip(1, Emit(1, 2, 3), WithName("Skip"), WithMetrics(m))
+		},
+		want: want{in: 3, out: 2},
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			m := newMockMetrics()
+			Drain(test.stage(m))
+
+			got := want{
+				in:         m.in[test.name],
+				out:        m.out[test.name],
+				processed:  m.processed[test.name],
+				errs:       m.errs[test.name],
+				canceled:   m.canceled[test.name],
+				batches:    m.batches[test.name],
+				queueWaits: m.queueWaits[test.name],
+			}
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("metrics = %+v, want %+v", got, test.want)
+			}
+			if inFlight := m.inFlight[test.name]; inFlight != 0 {
+				t.Errorf("inFlight = %d, want 0", inFlight)
+			}
+		})
+	}
+}
+
+func TestMetricsCancel(t *testing.T) {
+	t.Parallel()
+
+	// Cancel the context before anything is processed
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	m := newMockMetrics()
+	Drain(Process[int, int](ctx, &mockProcessor[int]{}, Emit(1, 2, 3), WithMetrics(m)))
+
+	// Stages are named after their kind by default
+	if got := m.canceled["Process"]; got != 3 {
+		t.Errorf("canceled = %d, want 3", got)
+	}
+	if got := m.processed["Process"]; got != 0 {
+		t.Errorf("processed = %d, want 0", got)
+	}
+}
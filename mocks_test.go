@@ -3,6 +3,7 @@ package pipeline
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 )
 
@@ -58,3 +59,82 @@ func containsAll[Item comparable](a, b []Item) bool {
 	}
 	return true
 }
+
+// mockMetrics is a mock of the Metrics interface that counts what it observes by stage
+type mockMetrics struct {
+	mu         sync.Mutex
+	in         map[string]int
+	out        map[string]int
+	processed  map[string]int
+	errs       map[string]int
+	canceled   map[string]int
+	inFlight   map[string]int
+	batches    map[string][]int
+	queueWaits map[string]int
+	dropped    map[string]int
+}
+
+func newMockMetrics() *mockMetrics {
+	return &mockMetrics{
+		in:         make(map[string]int),
+		out:        make(map[string]int),
+		processed:  make(map[string]int),
+		errs:       make(map[string]int),
+		canceled:   make(map[string]int),
+		inFlight:   make(map[string]int),
+		batches:    make(map[string][]int),
+		queueWaits: make(map[string]int),
+		dropped:    make(map[string]int),
+	}
+}
+
+func (m *mockMetrics) In(stage string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.in[stage]++
+}
+
+func (m *mockMetrics) Out(stage string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.out[stage]++
+}
+
+func (m *mockMetrics) Process(stage string, _ time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.processed[stage]++
+	if err != nil {
+		m.errs[stage]++
+	}
+}
+
+func (m *mockMetrics) Cancel(stage string, _ error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.canceled[stage]++
+}
+
+func (m *mockMetrics) InFlight(stage string, delta int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight[stage] += delta
+}
+
+func (m *mockMetrics) Batch(stage string, size int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.batches[stage] = append(m.batches[stage], size)
+}
+
+func (m *mockMetrics) QueueWait(stage string, _ time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queueWaits[stage]++
+}
+
+func (m *mockMetrics) Drop(stage string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dropped[stage]++
+}
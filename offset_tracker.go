@@ -0,0 +1,105 @@
+package pipeline
+
+import "sync"
+
+// OffsetTracker tracks the offsets of the items read from a partitioned log like a Kafka partition,
+// and commits an offset once it and every offset tracked before it are acked or nacked.
+// That way, the items that were not fully processed are read again after a restart: at-least-once delivery.
+// Nacked offsets are handed to a hook, e.g. to send their items to a dead letter queue, so they don't hold up the commits forever.
+// It is safe for concurrent use.
+type OffsetTracker struct {
+	mu      sync.Mutex
+	commit  func(offset int64)
+	nacked  func(offset int64, err error)
+	pending []*trackedOffset
+	offsets map[int64]*trackedOffset
+}
+
+type trackedOffset struct {
+	offset int64
+	// done is true once the offset is acked or nacked
+	done bool
+}
+
+// NewOffsetTracker creates an OffsetTracker that calls `commit` with the highest offset
+// that was acked or nacked along with every offset tracked before it.
+// Every nacked offset is passed to `nacked` with the error, before an offset at or after it is committed.
+// Calls to `commit` and `nacked` are serialized, and calls to `commit` are in increasing order.
+// It panics if either func is nil, since a nacked offset is committed after it is passed to `nacked`,
+// so ignoring nacks has to be spelled out with a `nacked` func that does nothing.
+func NewOffsetTracker(commit func(offset int64), nacked func(offset int64, err error)) *OffsetTracker {
+	if commit == nil || nacked == nil {
+		panic("pipeline: NewOffsetTracker needs a commit and a nacked func")
+	}
+	return &OffsetTracker{
+		commit:  commit,
+		nacked:  nacked,
+		offsets: make(map[int64]*trackedOffset),
+	}
+}
+
+// Track starts tracking an offset. Offsets must be tracked in increasing order, but they don't have to be consecutive.
+func (t *OffsetTracker) Track(offset int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	o := &trackedOffset{offset: offset}
+	t.pending = append(t.pending, o)
+	t.offsets[offset] = o
+}
+
+// Ack acks a tracked offset, committing the offsets that are now acked along with every offset before them.
+// Offsets that aren't tracked are ignored.
+func (t *OffsetTracker) Ack(offset int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.done(offset)
+}
+
+// Nack passes a tracked offset that could not be processed to the `nacked` hook with err,
+// then commits it like Ack, since it was dealt with. Offsets that aren't tracked are ignored.
+func (t *OffsetTracker) Nack(offset int64, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.offsets[offset]; ok {
+		t.nacked(offset, err)
+		t.done(offset)
+	}
+}
+
+// done marks a tracked offset as done, and commits the offsets that are now done along with every offset before them
+func (t *OffsetTracker) done(offset int64) {
+	o, ok := t.offsets[offset]
+	if !ok {
+		return
+	}
+	o.done = true
+	var committed *trackedOffset
+	for len(t.pending) > 0 && t.pending[0].done {
+		committed = t.pending[0]
+		t.pending[0] = nil
+		t.pending = t.pending[1:]
+		delete(t.offsets, committed.offset)
+	}
+	if committed != nil {
+		t.commit(committed.offset)
+	}
+}
+
+// Pending returns the number of tracked offsets that were not committed yet
+func (t *OffsetTracker) Pending() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.pending)
+}
+
+// TrackEnvelope tracks an offset and puts the value read at that offset in an envelope that acks or nacks it
+func TrackEnvelope[T any](t *OffsetTracker, offset int64, value T) Envelope[T] {
+	t.Track(offset)
+	return NewEnvelope(value, func(err error) {
+		if err != nil {
+			t.Nack(offset, err)
+			return
+		}
+		t.Ack(offset)
+	})
+}
@@ -0,0 +1,96 @@
+package pipeline
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestOffsetTracker(t *testing.T) {
+	t.Parallel()
+
+	var commits []int64
+	tracker := NewOffsetTracker(func(offset int64) {
+		commits = append(commits, offset)
+	}, func(offset int64, err error) {
+		t.Errorf("offset %d nacked with %s", offset, err)
+	})
+	for _, offset := range []int64{1, 2, 3, 5, 6} {
+		tracker.Track(offset)
+	}
+
+	for _, test := range []struct {
+		ack     int64
+		commits []int64
+	}{
+		// 1 isn't acked yet
+		{2, nil},
+		// 1 and 2 are
+		{1, []int64{2}},
+		// 4 was never tracked
+		{4, []int64{2}},
+		{6, []int64{2}},
+		{3, []int64{2, 3}},
+		{5, []int64{2, 3, 6}},
+	} {
+		tracker.Ack(test.ack)
+		if !reflect.DeepEqual(commits, test.commits) {
+			t.Errorf("after ack %d commits = %v, want %v", test.ack, commits, test.commits)
+		}
+	}
+	if pending := tracker.Pending(); pending != 0 {
+		t.Errorf("pending = %d, want 0", pending)
+	}
+}
+
+func TestTrackEnvelope(t *testing.T) {
+	t.Parallel()
+
+	var committed int64 = -1
+	nacked := make(map[int64]error)
+	tracker := NewOffsetTracker(func(offset int64) {
+		committed = offset
+	}, func(offset int64, err error) {
+		// The offset isn't committed until it is dealt with, like sending its item to a dead letter queue
+		if committed >= offset {
+			t.Errorf("offset %d nacked after %d was committed", offset, committed)
+		}
+		nacked[offset] = err
+	})
+	es := []Envelope[string]{
+		TrackEnvelope(tracker, 10, "a"),
+		TrackEnvelope(tracker, 11, "b"),
+		TrackEnvelope(tracker, 12, "c"),
+		TrackEnvelope(tracker, 13, "d"),
+	}
+
+	es[0].Ack()
+	es[2].Ack()
+	if committed != 10 {
+		t.Errorf("committed = %d, want 10", committed)
+	}
+
+	// A nacked offset is passed to the hook, so it doesn't hold up the offsets after it
+	errFailed := errors.New("failed")
+	es[1].Nack(errFailed)
+	if committed != 12 {
+		t.Errorf("committed = %d, want 12", committed)
+	}
+	if !reflect.DeepEqual(nacked, map[int64]error{11: errFailed}) {
+		t.Errorf("nacked = %v, want 11: %s", nacked, errFailed)
+	}
+	if pending := tracker.Pending(); pending != 1 {
+		t.Errorf("pending = %d, want 1", pending)
+	}
+}
+
+func TestNewOffsetTrackerWithoutNacked(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("NewOffsetTracker did not panic")
+		}
+	}()
+	NewOffsetTracker(func(int64) {}, nil)
+}
@@ -0,0 +1,100 @@
+package pipeline
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/deliveryhero/pipeline/v2/internal/clock"
+)
+
+// Option configures a stage, like Process or Collect
+type Option func(*config)
+
+// config is the configuration of a stage
+type config struct {
+	name    string
+	kind    string
+	metrics Metrics
+	graph   *Graph
+	node    *node
+	logger  *stageLogger
+	clock   Clock
+	// dropped is the func(Item) set WithDropped
+	dropped any
+	// aging is the wait set WithAging
+	aging time.Duration
+	// maxSize is the limit set WithMaxSize
+	maxSize int
+	// unmatched is the func(Item) set WithUnmatched
+	unmatched any
+	// lost is the func set WithLost
+	lost func(error)
+	// stageOptions are the options given to the stage that only some stages read, like WithMaxSize
+	stageOptions []string
+}
+
+// newConfig creates the config of a stage of the given kind.
+// Unless it is named by an Option, the stage is named after its kind.
+// It panics if the stage is given an option that only some stages read, unless it is one of the `supported` options,
+// rather than ignoring it.
+func newConfig(kind string, opts []Option, supported ...string) *config {
+	c := &config{
+		name:    kind,
+		kind:    kind,
+		metrics: NoopMetrics{},
+		clock:   clock.Real(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	for _, option := range c.stageOptions {
+		if !slices.Contains(supported, option) {
+			panic(fmt.Sprintf("pipeline: %s was given to %s, which doesn't read it", option, kind))
+		}
+	}
+	if c.logger != nil {
+		c.logger.sampler = newSampler(c.clock, logInterval, logBurst)
+		c.metrics = multiMetrics{c.metrics, c.logger}
+	}
+	// The graph counts the items passing through the stage as well
+	if c.graph != nil {
+		c.node = c.graph.add(c.name, c.kind, c.clock)
+		c.metrics = multiMetrics{c.metrics, c.node}
+	}
+	return c
+}
+
+// connect adds the channels the stage reads from and writes to to its graph, if it has one
+func connect[Out any](c *config, out chan Out, ins ...any) {
+	if c.node != nil {
+		// Stages read from receive only chans
+		c.node.connect((<-chan Out)(out), ins...)
+	}
+}
+
+// stageOption records that the option with the given name, which only some stages read, was given to the stage, see newConfig
+func (c *config) stageOption(name string) {
+	c.stageOptions = append(c.stageOptions, name)
+}
+
+// callback returns the func(Item) set by `option`, or nil if it was not set.
+// It panics if the func takes another type than the items of the stage, rather than never calling it.
+func callback[Item any](f any, option string) func(Item) {
+	if f == nil {
+		return nil
+	}
+	typed, ok := f.(func(Item))
+	if !ok {
+		var zero Item
+		panic(fmt.Sprintf("pipeline: %s(%T) was given to a stage of %T", option, f, zero))
+	}
+	return typed
+}
+
+// WithName names a stage, so it can be told apart from other stages of the same kind
+func WithName(name string) Option {
+	return func(c *config) {
+		c.name = name
+	}
+}
@@ -0,0 +1,21 @@
+module github.com/deliveryhero/pipeline/v2/otel
+
+go 1.21
+
+// TODO: require the first tagged version with the Tracer API once it is released.
+// Until then the adapter only builds against the pipeline module of the go.work in the root of the repo.
+require (
+	github.com/deliveryhero/pipeline/v2 v2.0.0
+	go.opentelemetry.io/otel v1.28.0
+	go.opentelemetry.io/otel/sdk v1.28.0
+	go.opentelemetry.io/otel/trace v1.28.0
+)
+
+require (
+	github.com/deliveryhero/pipeline v0.4.0 // indirect
+	github.com/go-logr/logr v1.4.2 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	go.opentelemetry.io/otel/metric v1.28.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+)
@@ -0,0 +1,81 @@
+// Package otel traces the items of a pipeline with OpenTelemetry.
+//
+// Example Usage
+//
+//	tracer := otel.NewTracer(otelapi.Tracer("orders"))
+//	p := pipeline.TraceItems(ctx, orders)
+//	p = pipeline.Process(ctx, pipeline.Trace("geocode", tracer, geocode), p)
+//	p = pipeline.Process(ctx, pipeline.Trace("assign", tracer, pipeline.Sequence(
+//		pipeline.TraceStep("find", tracer, find),
+//		pipeline.TraceStep("notify", tracer, notify),
+//	)), p)
+package otel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/deliveryhero/pipeline/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewTracer creates a `pipeline.Tracer` that starts its spans with an OpenTelemetry `trace.Tracer`
+func NewTracer(t trace.Tracer) pipeline.Tracer {
+	return &tracer{t}
+}
+
+// tracer implements pipeline.Tracer
+type tracer struct {
+	t trace.Tracer
+}
+
+func (t *tracer) Start(ctx context.Context, parent any, name string) (context.Context, pipeline.Span) {
+	if sc, ok := parent.(trace.SpanContext); ok && sc.IsValid() {
+		ctx = trace.ContextWithSpanContext(ctx, sc)
+	}
+	ctx, s := t.t.Start(ctx, name)
+	return ctx, &span{s}
+}
+
+// span implements pipeline.Span
+type span struct {
+	s trace.Span
+}
+
+func (s *span) SetAttribute(key string, value any) {
+	s.s.SetAttributes(attributeOf(key, value))
+}
+
+func (s *span) SetError(err error) {
+	s.s.RecordError(err)
+	s.s.SetStatus(codes.Error, err.Error())
+}
+
+func (s *span) End() {
+	s.s.End()
+}
+
+// SpanContext returns the `trace.SpanContext` of the span
+func (s *span) SpanContext() any {
+	return s.s.SpanContext()
+}
+
+// attributeOf converts a key value pair to an attribute
+func attributeOf(key string, value any) attribute.KeyValue {
+	switch v := value.(type) {
+	case string:
+		return attribute.String(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case float64:
+		return attribute.Float64(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	default:
+		return attribute.String(key, fmt.Sprint(v))
+	}
+}
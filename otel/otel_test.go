@@ -0,0 +1,98 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/deliveryhero/pipeline/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracer(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer provider.Shutdown(context.Background())
+	tracer := NewTracer(provider.Tracer("test"))
+
+	inc := pipeline.NewProcessor(func(_ context.Context, i int) (int, error) {
+		return i + 1, nil
+	}, func(int, error) {})
+	fail := pipeline.NewProcessor(func(_ context.Context, i int) (int, error) {
+		if i > 2 {
+			return 0, errors.New("too big")
+		}
+		return i, nil
+	}, func(int, error) {})
+
+	// Trace 2 items through 2 stages, where the second item fails in the second stage
+	p := pipeline.TraceItems(context.Background(), pipeline.Emit(0, 1))
+	p = pipeline.Process(context.Background(), pipeline.Trace("inc", tracer, pipeline.Sequence(
+		pipeline.TraceStep("first", tracer, inc),
+		pipeline.TraceStep("second", tracer, inc),
+	)), p)
+	p = pipeline.Process(context.Background(), pipeline.Trace("check", tracer, fail), p)
+	pipeline.Drain(p)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 8 {
+		t.Fatalf("len(spans) = %d, want 8", len(spans))
+	}
+
+	// Every item has its own trace that covers both stages
+	traces := make(map[string][]tracetest.SpanStub)
+	for _, s := range spans {
+		id := s.SpanContext.TraceID().String()
+		traces[id] = append(traces[id], s)
+	}
+	if len(traces) != 2 {
+		t.Fatalf("len(traces) = %d, want 2", len(traces))
+	}
+	for id, spans := range traces {
+		byName := make(map[string]tracetest.SpanStub)
+		for _, s := range spans {
+			byName[s.Name] = s
+		}
+		incSpan, checkSpan := byName["inc"], byName["check"]
+		if incSpan.Parent.IsValid() {
+			t.Errorf("[%s] inc has a parent, want a root span", id)
+		}
+		if checkSpan.Parent.SpanID() != incSpan.SpanContext.SpanID() {
+			t.Errorf("[%s] check is not a child of inc", id)
+		}
+		for _, name := range []string{"first", "second"} {
+			if byName[name].Parent.SpanID() != incSpan.SpanContext.SpanID() {
+				t.Errorf("[%s] %s is not a child of inc", id, name)
+			}
+		}
+		if !hasAttribute(checkSpan.Attributes, attribute.String("pipeline.stage", "check")) {
+			t.Errorf("[%s] check attributes = %v", id, checkSpan.Attributes)
+		}
+	}
+
+	// Only the check of the second item failed
+	var failed int
+	for _, s := range spans {
+		if s.Status.Code == codes.Error {
+			failed++
+			if s.Name != "check" {
+				t.Errorf("%s failed, want check", s.Name)
+			}
+		}
+	}
+	if failed != 1 {
+		t.Errorf("failed = %d, want 1", failed)
+	}
+}
+
+func hasAttribute(attrs []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, a := range attrs {
+		if a == want {
+			return true
+		}
+	}
+	return false
+}
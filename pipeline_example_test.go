@@ -142,3 +142,57 @@ func Example_pipelineShutsDownWhenContainerIsKilled() {
 	// error processing '4': context canceled
 	// exiting after the input channel is closed
 }
+
+// This example demonstrates a pipeline that finishes processing
+// the items it already started on when the os / container kills it
+func Example_pipelineDrainsWhenContainerIsKilled() {
+	// Stop emitting new numbers when the os.Kill or os.Interrupt signal is sent
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Kill, os.Interrupt)
+	defer cancel()
+
+	// But give the numbers already in the pipeline up to a second to be processed
+	sources, stages, cancelShutdown := pipeline.GracefulShutdown(ctx, time.Second)
+	defer cancelShutdown()
+
+	// Create a pipeline that keeps emitting numbers sequentially until the sources context is canceled
+	var count int
+	p := pipeline.Emitter(sources, func() int {
+		count++
+		return count
+	})
+
+	// Buffer a few numbers, then slowly double them
+	p = pipeline.Process(stages, pipeline.NewProcessor(func(ctx context.Context, i int) (int, error) {
+		time.Sleep(time.Millisecond)
+		return i * 2, nil
+	}, func(i int, err error) {
+		fmt.Printf("could not double '%v': %s\n", i, err)
+	}), pipeline.Buffer(2, p))
+
+	// Wait a few milliseconds an simulate the os.Interrupt signal
+	go func() {
+		time.Sleep(time.Millisecond * 5 / 2)
+		fmt.Print("\n--- os kills the app ---\n\n")
+		syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+	}()
+
+	// Finally, lets print the results and see what happened
+	for result := range p {
+		fmt.Printf("result: %d\n", result)
+	}
+
+	fmt.Println("exiting after the numbers in the pipeline are processed")
+
+	// Example Output:
+	// result: 2
+	// result: 4
+	//
+	// --- os kills the app ---
+	//
+	// result: 6
+	// result: 8
+	// result: 10
+	// result: 12
+	// result: 14
+	// exiting after the numbers in the pipeline are processed
+}
@@ -0,0 +1,21 @@
+package pipelinetest
+
+import (
+	"time"
+
+	"github.com/deliveryhero/pipeline/v2/internal/clock"
+)
+
+// FakeClock is a `pipeline.Clock` whose time only changes when it is advanced, see NewFakeClock
+type FakeClock = clock.Fake
+
+// NewFakeClock creates a clock set to `now`, which can be passed to the stages that wait with `pipeline.WithClock`.
+// Advance it to fire their timers, after waiting for them with BlockUntil:
+//
+//	clock := pipelinetest.NewFakeClock(time.Now())
+//	out := pipeline.Collect(ctx, 10, time.Minute, in, pipeline.WithClock(clock))
+//	clock.BlockUntil(1)
+//	clock.Advance(time.Minute) // Flushes the batch
+func NewFakeClock(now time.Time) *FakeClock {
+	return clock.NewFake(now)
+}
@@ -0,0 +1,50 @@
+package pipelinetest
+
+import (
+	"testing"
+	"time"
+)
+
+// Collect reads everything from `in` until it closes and returns it.
+// It fails the test if `in` doesn't close within the timeout.
+func Collect[Item any](t testing.TB, in <-chan Item, timeout time.Duration) []Item {
+	t.Helper()
+	var is []Item
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		select {
+		case i, open := <-in:
+			if !open {
+				return is
+			}
+			is = append(is, i)
+		case <-deadline.C:
+			t.Fatalf("channel did not close within %s, received %v", timeout, is)
+			return is
+		}
+	}
+}
+
+// CollectN reads `n` items from `in` and returns them.
+// It fails the test if `in` closes before or doesn't send `n` items within the timeout.
+func CollectN[Item any](t testing.TB, in <-chan Item, n int, timeout time.Duration) []Item {
+	t.Helper()
+	is := make([]Item, 0, n)
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for len(is) < n {
+		select {
+		case i, open := <-in:
+			if !open {
+				t.Fatalf("channel closed after %d of %d items, received %v", len(is), n, is)
+				return is
+			}
+			is = append(is, i)
+		case <-deadline.C:
+			t.Fatalf("received %d of %d items within %s, received %v", len(is), n, timeout, is)
+			return is
+		}
+	}
+	return is
+}
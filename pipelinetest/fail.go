@@ -0,0 +1,62 @@
+package pipelinetest
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// FailWhen returns a ProcessFunc that fails with err for the inputs `fail` returns true for,
+// and processes the others with `process`
+func FailWhen[Input, Output any](fail func(Input) bool, err error, process ProcessFunc[Input, Output]) ProcessFunc[Input, Output] {
+	return func(ctx context.Context, i Input) (Output, error) {
+		if fail(i) {
+			var zero Output
+			return zero, err
+		}
+		return process(ctx, i)
+	}
+}
+
+// FailEvery returns a ProcessFunc that fails with err on every nth call,
+// and processes the inputs of the other calls with `process`.
+// It panics if n is not positive.
+func FailEvery[Input, Output any](n int, err error, process ProcessFunc[Input, Output]) ProcessFunc[Input, Output] {
+	if n <= 0 {
+		panic(fmt.Sprintf("pipelinetest: FailEvery needs a positive n, not %d", n))
+	}
+	var calls int64
+	return func(ctx context.Context, i Input) (Output, error) {
+		if atomic.AddInt64(&calls, 1)%int64(n) == 0 {
+			var zero Output
+			return zero, err
+		}
+		return process(ctx, i)
+	}
+}
+
+// Slow returns a ProcessFunc that waits for `d` before processing each input with `process`.
+// It fails with the context error if the context is canceled while it waits.
+func Slow[Input, Output any](d time.Duration, process ProcessFunc[Input, Output]) ProcessFunc[Input, Output] {
+	return func(ctx context.Context, i Input) (Output, error) {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			var zero Output
+			return zero, ctx.Err()
+		case <-timer.C:
+			return process(ctx, i)
+		}
+	}
+}
+
+// Block returns a ProcessFunc that blocks until the context is canceled, then fails with the context error
+func Block[Input, Output any]() ProcessFunc[Input, Output] {
+	return func(ctx context.Context, _ Input) (Output, error) {
+		<-ctx.Done()
+		var zero Output
+		return zero, ctx.Err()
+	}
+}
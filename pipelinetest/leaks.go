@@ -0,0 +1,72 @@
+package pipelinetest
+
+import (
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stageLabel is the pprof label the pipeline library sets on the goroutines of every stage, see `pipeline.StageLabel`
+const stageLabel = `"pipeline.stage":`
+
+// leakTimeout is how long AssertNoLeaks waits for the goroutines of the pipeline to exit
+const leakTimeout = time.Second
+
+// AssertNoLeaks fails the test if the goroutines of the stages it started are still running when it ends.
+// Call it at the start of the test. Goroutines of stages that were already running are ignored,
+// but tests running in parallel can't be told apart, so don't use it in tests that run in parallel with tests that leak.
+func AssertNoLeaks(t testing.TB) {
+	t.Helper()
+	before := stageGoroutines()
+	t.Cleanup(func() {
+		var leaked []string
+		for deadline := time.Now().Add(leakTimeout); ; time.Sleep(10 * time.Millisecond) {
+			leaked = leaked[:0]
+			for labels, g := range stageGoroutines() {
+				if g.count > before[labels].count {
+					leaked = append(leaked, g.stacks...)
+				}
+			}
+			if len(leaked) == 0 || time.Now().After(deadline) {
+				break
+			}
+		}
+		if len(leaked) > 0 {
+			t.Errorf("goroutines of the pipeline are still running after %s:\n%s", leakTimeout, strings.Join(leaked, "\n"))
+		}
+	})
+}
+
+// goroutines are the goroutines with the same labels in the goroutine profile
+type goroutines struct {
+	count  int
+	stacks []string
+}
+
+// stageGoroutines returns the goroutines of the stages of the pipeline library by their labels
+func stageGoroutines() map[string]goroutines {
+	var b strings.Builder
+	_ = pprof.Lookup("goroutine").WriteTo(&b, 1)
+	stages := make(map[string]goroutines)
+	// After its header, the profile has a block for each stack and set of labels, starting with the number of goroutines
+	_, profile, _ := strings.Cut(b.String(), "\n")
+	for _, block := range strings.Split(profile, "\n\n") {
+		lines := strings.Split(block, "\n")
+		if len(lines) < 2 || !strings.HasPrefix(lines[1], "# labels: ") || !strings.Contains(lines[1], stageLabel) {
+			continue
+		}
+		n, _, _ := strings.Cut(lines[0], " @ ")
+		count, err := strconv.Atoi(n)
+		if err != nil {
+			continue
+		}
+		labels := strings.TrimPrefix(lines[1], "# labels: ")
+		g := stages[labels]
+		g.count += count
+		g.stacks = append(g.stacks, block)
+		stages[labels] = g
+	}
+	return stages
+}
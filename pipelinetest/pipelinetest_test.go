@@ -0,0 +1,179 @@
+package pipelinetest_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline/v2"
+	"github.com/deliveryhero/pipeline/v2/pipelinetest"
+)
+
+var errFailed = errors.New("failed")
+
+func double(_ context.Context, i int) (int, error) {
+	return i * 2, nil
+}
+
+func TestProcessor(t *testing.T) {
+	pipelinetest.AssertNoLeaks(t)
+
+	p := pipelinetest.NewProcessor(pipelinetest.FailWhen(func(i int) bool {
+		return i%2 == 0
+	}, errFailed, double))
+	out := pipeline.ProcessConcurrently[int, int](context.Background(), 3, p, pipeline.Emit(1, 2, 3, 4, 5))
+	got := pipelinetest.Collect(t, out, time.Second)
+
+	if !sameItems(got, []int{2, 6, 10}) {
+		t.Errorf("out = %v, want [2 6 10]", got)
+	}
+	if processed := p.Processed(); !sameItems(processed, []int{1, 3, 5}) {
+		t.Errorf("processed = %v, want [1 3 5]", processed)
+	}
+	if canceled := p.Canceled(); !sameItems(canceled, []int{2, 4}) {
+		t.Errorf("canceled = %v, want [2 4]", canceled)
+	}
+	if errs := p.Errors(); !reflect.DeepEqual(errs, []error{errFailed, errFailed}) {
+		t.Errorf("errors = %v, want two %s", errs, errFailed)
+	}
+}
+
+func TestFailEvery(t *testing.T) {
+	p := pipelinetest.NewProcessor(pipelinetest.FailEvery(3, errFailed, double))
+	got := pipelinetest.Collect(t, pipeline.Process[int, int](context.Background(), p, pipeline.Emit(1, 2, 3, 4, 5, 6, 7)), time.Second)
+	if want := []int{2, 4, 8, 10, 14}; !reflect.DeepEqual(got, want) {
+		t.Errorf("out = %v, want %v", got, want)
+	}
+	if want := []int{3, 6}; !reflect.DeepEqual(p.Canceled(), want) {
+		t.Errorf("canceled = %v, want %v", p.Canceled(), want)
+	}
+}
+
+func TestFailEveryNotPositive(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("FailEvery(%d) did not panic", n)
+				}
+			}()
+			pipelinetest.FailEvery(n, errFailed, double)
+		}()
+	}
+}
+
+func TestSlowAndBlock(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := pipelinetest.Slow(10*time.Millisecond, double)(ctx, 1); err != nil || time.Since(start) < 10*time.Millisecond {
+		t.Errorf("slow returned %v after %s", err, time.Since(start))
+	}
+	if _, err := pipelinetest.Block[int, int]()(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("block returned %v, want %s", err, context.DeadlineExceeded)
+	}
+	if _, err := pipelinetest.Slow(time.Minute, double)(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("slow returned %v, want %s", err, context.DeadlineExceeded)
+	}
+}
+
+func TestCollectN(t *testing.T) {
+	in := make(chan int)
+	defer close(in)
+	go func() {
+		in <- 1
+		in <- 2
+	}()
+	if got := pipelinetest.CollectN(t, in, 2, time.Second); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("got %v, want [1 2]", got)
+	}
+}
+
+// recordingT records the failures of a test instead of failing it
+type recordingT struct {
+	testing.TB
+	cleanups []func()
+	errors   []string
+	fatal    bool
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Cleanup(f func()) {
+	t.cleanups = append(t.cleanups, f)
+}
+
+func (t *recordingT) Errorf(format string, args ...any) {
+	t.errors = append(t.errors, format)
+}
+
+func (t *recordingT) Fatalf(format string, args ...any) {
+	t.errors = append(t.errors, format)
+	t.fatal = true
+}
+
+// end runs the cleanups of the test
+func (t *recordingT) end() {
+	for i := len(t.cleanups) - 1; i >= 0; i-- {
+		t.cleanups[i]()
+	}
+}
+
+func TestAssertNoLeaks(t *testing.T) {
+	rt := &recordingT{TB: t}
+	pipelinetest.AssertNoLeaks(rt)
+
+	// Nothing reads the out chan of the stage, so its goroutine never exits
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	p := pipelinetest.NewProcessor(func(_ context.Context, i int) (int, error) {
+		close(started)
+		return i, nil
+	})
+	out := pipeline.Process[int, int](ctx, p, pipeline.Emit(1), pipeline.WithName("leaky"))
+	<-started
+	rt.end()
+	if len(rt.errors) != 1 || !strings.Contains(rt.errors[0], "still running") {
+		t.Errorf("errors = %v, want the leak to be reported", rt.errors)
+	}
+
+	// Once it is read, the goroutine exits
+	rt = &recordingT{TB: t}
+	pipelinetest.AssertNoLeaks(rt)
+	cancel()
+	pipeline.Drain(out)
+	rt.end()
+	if len(rt.errors) != 0 {
+		t.Errorf("errors = %v, want none", rt.errors)
+	}
+}
+
+func TestCollectTimesOut(t *testing.T) {
+	rt := &recordingT{TB: t}
+	in := make(chan int)
+	pipelinetest.Collect(rt, in, 10*time.Millisecond)
+	if !rt.fatal {
+		t.Error("collect did not time out")
+	}
+}
+
+// sameItems returns true if a and b contain the same items in any order
+func sameItems(a, b []int) bool {
+	counts := make(map[int]int)
+	for _, i := range a {
+		counts[i]++
+	}
+	for _, i := range b {
+		counts[i]--
+	}
+	for _, n := range counts {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+// Package pipelinetest helps test pipelines without sleeping.
+// It has a Processor that records what it processes and cancels, funcs that inject failures into it,
+// helpers that collect channels with a timeout, a FakeClock for the stages that wait
+// and AssertNoLeaks, which checks that the goroutines of a pipeline exit.
+//
+// Example Usage
+//
+//	func TestDouble(t *testing.T) {
+//		pipelinetest.AssertNoLeaks(t)
+//		p := pipelinetest.NewProcessor(pipelinetest.FailWhen(func(i int) bool {
+//			return i == 2
+//		}, errors.New("two"), double))
+//		out := pipeline.Process[int, int](context.Background(), p, pipeline.Emit(1, 2, 3))
+//		got := pipelinetest.Collect(t, out, time.Second)
+//		// got is [2 6], p.Canceled() is [2]
+//	}
+package pipelinetest
+
+import (
+	"context"
+	"sync"
+)
+
+// ProcessFunc processes an input, like `pipeline.Processor.Process`
+type ProcessFunc[Input, Output any] func(ctx context.Context, i Input) (Output, error)
+
+// Processor is a `pipeline.Processor` that records every input it processes and cancels.
+// It is safe for concurrent use, so it can be used with ProcessConcurrently.
+type Processor[Input, Output any] struct {
+	process ProcessFunc[Input, Output]
+
+	mu        sync.Mutex
+	processed []Input
+	canceled  []Input
+	errs      []error
+}
+
+// NewProcessor creates a Processor that processes inputs with the process func
+func NewProcessor[Input, Output any](process ProcessFunc[Input, Output]) *Processor[Input, Output] {
+	return &Processor[Input, Output]{process: process}
+}
+
+// Identity creates a Processor that returns every input as its output
+func Identity[Item any]() *Processor[Item, Item] {
+	return NewProcessor(func(_ context.Context, i Item) (Item, error) {
+		return i, nil
+	})
+}
+
+// Process calls the process func, recording the input if it returns no error
+func (p *Processor[Input, Output]) Process(ctx context.Context, i Input) (Output, error) {
+	o, err := p.process(ctx, i)
+	if err == nil {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.processed = append(p.processed, i)
+	}
+	return o, err
+}
+
+// Cancel records the input and the error it was canceled with
+func (p *Processor[Input, Output]) Cancel(i Input, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.canceled = append(p.canceled, i)
+	p.errs = append(p.errs, err)
+}
+
+// Processed returns the inputs that were processed without an error, in the order they were processed
+func (p *Processor[Input, Output]) Processed() []Input {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Input(nil), p.processed...)
+}
+
+// Canceled returns the inputs that were canceled, in the order they were canceled
+func (p *Processor[Input, Output]) Canceled() []Input {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Input(nil), p.canceled...)
+}
+
+// Errors returns the errors the inputs were canceled with, in the same order as Canceled
+func (p *Processor[Input, Output]) Errors() []error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]error(nil), p.errs...)
+}
@@ -0,0 +1,151 @@
+package pipeline
+
+import (
+	"container/heap"
+	"context"
+	"time"
+)
+
+// WithAging makes PriorityBuffer raise the priority of a buffered item by 1 for every `wait` it spends in the buffer,
+// so items with a low priority are eventually passed on even while items with a higher priority keep arriving.
+// It is only read by PriorityBuffer, and any other stage it is given to panics when it is created.
+func WithAging(wait time.Duration) Option {
+	return func(c *config) {
+		c.aging = wait
+		c.stageOption("WithAging")
+	}
+}
+
+// PriorityBuffer buffers up to `capacity` items from `in <-chan Item`, and passes the buffered item with the highest priority to the out channel next.
+// The priority of each item is returned by `priorityFn`, and items with the same priority are passed on in the order they were received.
+// Once the buffer is full, no more items are received until an item is passed on, so the upstream stages are slowed down like Buffer.
+// Use WithAging so items with a low priority can't be starved by items with a higher priority.
+// The out channel is closed after the in channel is closed and the buffer is fully drained.
+// If the context is canceled, the buffered items are passed on by priority, and the rest of the in channel is passed on in order.
+func PriorityBuffer[Item any](ctx context.Context, priorityFn func(Item) int, capacity int, in <-chan Item, opts ...Option) <-chan Item {
+	c := newConfig("PriorityBuffer", opts, "WithAging")
+	out := make(chan Item)
+	connect(c, out, in)
+	if capacity < 1 {
+		capacity = 1
+	}
+	goStage(ctx, c.name, c.kind, func() {
+		defer c.closed()
+		defer close(out)
+		q := &priorityQueue[Item]{aging: c.aging}
+		var received uint64
+		for ctx.Err() == nil {
+			if in == nil && q.Len() == 0 {
+				return
+			}
+			// A nil chan blocks forever, so nothing is received while the buffer is full and nothing is sent while it is empty
+			var recv <-chan Item
+			if q.Len() < capacity {
+				recv = in
+				c.node.blockedOnReceive(1)
+			}
+			var send chan<- Item
+			var next Item
+			if q.Len() > 0 {
+				send, next = out, q.items[0].item
+				c.node.blockedOnSend(1)
+			}
+			unblock := func() {
+				if recv != nil {
+					c.node.blockedOnReceive(-1)
+				}
+				if send != nil {
+					c.node.blockedOnSend(-1)
+				}
+			}
+			select {
+			case <-ctx.Done():
+				unblock()
+			case i, open := <-recv:
+				unblock()
+				if !open {
+					in = nil
+					continue
+				}
+				c.metrics.In(c.name)
+				c.node.hold(1)
+				received++
+				heap.Push(q, prioritized[Item]{item: i, priority: priorityFn(i), at: c.clock.Now(), seq: received})
+			case send <- next:
+				unblock()
+				heap.Pop(q)
+				c.node.hold(-1)
+				c.metrics.Out(c.name)
+			}
+		}
+
+		// The context is canceled, so pass on the buffered items and stop prioritizing
+		for q.Len() > 0 {
+			deliver(c.node, out, heap.Pop(q).(prioritized[Item]).item)
+			c.node.hold(-1)
+			c.metrics.Out(c.name)
+		}
+		for in != nil {
+			i, open := receive(c.node, in)
+			if !open {
+				return
+			}
+			c.metrics.In(c.name)
+			deliver(c.node, out, i)
+			c.metrics.Out(c.name)
+		}
+	})
+	return out
+}
+
+// prioritized is an item buffered by PriorityBuffer
+type prioritized[Item any] struct {
+	item     Item
+	priority int
+	// at is when the item was received
+	at time.Time
+	// seq orders the items with the same priority by when they were received
+	seq uint64
+}
+
+// priorityQueue is a heap of items, with the item to pass on next at the top
+type priorityQueue[Item any] struct {
+	items []prioritized[Item]
+	aging time.Duration
+}
+
+func (q *priorityQueue[Item]) Len() int {
+	return len(q.items)
+}
+
+func (q *priorityQueue[Item]) Less(i, j int) bool {
+	a, b := q.items[i], q.items[j]
+	if q.aging > 0 {
+		// Every item ages at the same pace, so an item that was received earlier is ahead by the time it waited longer,
+		// and the order of the items never changes while they are buffered
+		ra, rb := a.at.Add(-time.Duration(a.priority)*q.aging), b.at.Add(-time.Duration(b.priority)*q.aging)
+		if !ra.Equal(rb) {
+			return ra.Before(rb)
+		}
+	} else if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	return a.seq < b.seq
+}
+
+func (q *priorityQueue[Item]) Swap(i, j int) {
+	q.items[i], q.items[j] = q.items[j], q.items[i]
+}
+
+func (q *priorityQueue[Item]) Push(x any) {
+	q.items = append(q.items, x.(prioritized[Item]))
+}
+
+func (q *priorityQueue[Item]) Pop() any {
+	last := len(q.items) - 1
+	x := q.items[last]
+	// Don't keep a reference to the item
+	q.items[last] = prioritized[Item]{}
+	q.items = q.items[:last]
+	return x
+}
@@ -0,0 +1,159 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline/v2/pipelinetest"
+)
+
+// job is an item with a priority
+type job struct {
+	id       int
+	priority int
+}
+
+// jobPriority returns the priority of a job
+func jobPriority(j job) int {
+	return j.priority
+}
+
+func TestPriorityBuffer(t *testing.T) {
+	t.Parallel()
+
+	// step sends a job after advancing the clock
+	type step struct {
+		advance time.Duration
+		job     job
+	}
+	type args struct {
+		aging time.Duration
+		steps []step
+	}
+	type want struct {
+		out []int
+	}
+	for _, test := range []struct {
+		name string
+		args args
+		want want
+	}{{
+		name: "passes on the job with the highest priority first",
+		args: args{
+			steps: []step{
+				{job: job{1, 0}},
+				{job: job{2, 2}},
+				{job: job{3, 1}},
+				{job: job{4, 2}},
+			},
+		},
+		want: want{
+			out: []int{2, 4, 3, 1},
+		},
+	}, {
+		name: "jobs that waited are aged",
+		args: args{
+			aging: time.Second,
+			steps: []step{
+				{job: job{1, 0}},
+				{advance: 3 * time.Second, job: job{2, 2}},
+				{job: job{3, 5}},
+			},
+		},
+		want: want{
+			out: []int{3, 1, 2},
+		},
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			g := NewGraph()
+			clock := pipelinetest.NewFakeClock(time.Now())
+			in := make(chan job)
+			out := PriorityBuffer(ctx, jobPriority, len(test.args.steps), in, WithGraph(g), WithClock(clock), WithAging(test.args.aging))
+
+			// Nothing reads from the buffer until every job is buffered
+			for n, s := range test.args.steps {
+				clock.Advance(s.advance)
+				in <- s.job
+				waitFor(t, func() bool { return g.Status()[0].In == int64(n+1) })
+			}
+			close(in)
+
+			var ids []int
+			for _, j := range pipelinetest.Collect(t, out, time.Second) {
+				ids = append(ids, j.id)
+			}
+			if !reflect.DeepEqual(ids, test.want.out) {
+				t.Errorf("out = %v, want %v", ids, test.want.out)
+			}
+		})
+	}
+}
+
+func TestPriorityBufferCapacity(t *testing.T) {
+	t.Parallel()
+
+	g := NewGraph()
+	out := PriorityBuffer(context.Background(), jobPriority, 2, Emit(job{1, 0}, job{2, 1}, job{3, 2}, job{4, 3}), WithGraph(g))
+
+	// The buffer stops receiving once it is full
+	waitFor(t, func() bool {
+		s := g.Status()[0]
+		return s.In == 2 && s.Held == 2 && s.BlockedOnSend == 1 && s.BlockedOnReceive == 0
+	})
+
+	var ids []int
+	for _, j := range pipelinetest.Collect(t, out, time.Second) {
+		ids = append(ids, j.id)
+	}
+	sort.Ints(ids)
+	if !reflect.DeepEqual(ids, []int{1, 2, 3, 4}) {
+		t.Errorf("out = %v, want every job", ids)
+	}
+}
+
+func TestPriorityBufferCanceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	g := NewGraph()
+	in := make(chan job)
+	out := PriorityBuffer(ctx, jobPriority, 2, in, WithGraph(g))
+	in <- job{1, 0}
+	in <- job{2, 1}
+	waitFor(t, func() bool { return g.Status()[0].In == 2 })
+
+	// The buffered jobs are passed on by priority, and the rest in order
+	cancel()
+	go func() {
+		defer close(in)
+		in <- job{3, 0}
+		in <- job{4, 1}
+	}()
+	var ids []int
+	for _, j := range pipelinetest.Collect(t, out, time.Second) {
+		ids = append(ids, j.id)
+	}
+	if !reflect.DeepEqual(ids, []int{2, 1, 3, 4}) {
+		t.Errorf("out = %v, want [2 1 3 4]", ids)
+	}
+}
+
+func TestWithAgingOfAnotherStage(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Buffer did not panic")
+		}
+	}()
+	// Only PriorityBuffer ages its items
+	Buffer(1, make(chan int), WithAging(time.Second))
+}
@@ -2,6 +2,8 @@ package pipeline
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/deliveryhero/pipeline/v2/semaphore"
 )
@@ -10,56 +12,91 @@ import (
 // When `Processor.Process` returns an `Output`, it will be sent to the output `<-chan Output`.
 // If `Processor.Process` returns an error, `Processor.Cancel` will be called with the corresponding input and error message.
 // Finally, if the `Context` is canceled, all inputs remaining in the `in <-chan Input` will go directly to `Processor.Cancel`.
-func Process[Input, Output any](ctx context.Context, processor Processor[Input, Output], in <-chan Input) <-chan Output {
+func Process[Input, Output any](ctx context.Context, processor Processor[Input, Output], in <-chan Input, opts ...Option) <-chan Output {
+	c := newConfig("Process", opts)
 	out := make(chan Output)
-	go func() {
-		for i := range in {
-			process(ctx, processor, i, out)
+	connect(c, out, in)
+	goStage(ctx, c.name, c.kind, func() {
+		for {
+			i, open := receive(c.node, in)
+			received := c.clock.Now()
+			if !open {
+				break
+			}
+			c.metrics.In(c.name)
+			process(ctx, c, processor, i, received, out)
 		}
 		close(out)
-	}()
+		c.closed()
+	})
 	return out
 }
 
 // ProcessConcurrently fans the in channel out to multiple Processors running concurrently,
 // then it fans the out channels of the Processors back into a single out chan
-func ProcessConcurrently[Input, Output any](ctx context.Context, concurrently int, p Processor[Input, Output], in <-chan Input) <-chan Output {
+func ProcessConcurrently[Input, Output any](ctx context.Context, concurrently int, p Processor[Input, Output], in <-chan Input, opts ...Option) <-chan Output {
+	c := newConfig("ProcessConcurrently", opts)
 	// Create the out chan
 	out := make(chan Output)
-	go func() {
+	connect(c, out, in)
+	goStage(ctx, c.name, c.kind, func() {
 		// Perform Process concurrently times
 		sem := semaphore.New(concurrently)
-		for i := range in {
+		c.node.limit(sem)
+		for {
+			i, open := receive(c.node, in)
+			received := c.clock.Now()
+			if !open {
+				break
+			}
+			c.metrics.In(c.name)
 			sem.Add(1)
 			go func(i Input) {
-				process(ctx, p, i, out)
+				process(ctx, c, p, i, received, out)
 				sem.Done()
 			}(i)
 		}
 		// Close the out chan after all of the Processors finish executing
 		sem.Wait()
 		close(out)
-	}()
+		c.closed()
+	})
 	return out
 }
 
 func process[A, B any](
 	ctx context.Context,
+	c *config,
 	processor Processor[A, B],
 	i A,
+	received time.Time,
 	out chan<- B,
 ) {
 	select {
 	// When the context is canceled, Cancel all inputs
 	case <-ctx.Done():
+		c.metrics.Cancel(c.name, ctx.Err())
 		processor.Cancel(i, ctx.Err())
 	// Otherwise, Process all inputs
 	default:
+		c.metrics.QueueWait(c.name, c.clock.Now().Sub(received))
+		c.metrics.InFlight(c.name, 1)
+		defer c.metrics.InFlight(c.name, -1)
+		start := c.clock.Now()
 		result, err := processor.Process(ctx, i)
+		if errors.Is(err, ErrDuplicate) {
+			// Duplicates are dropped rather than failed, see Dedupe
+			reportDrop(c.metrics, c.name)
+			processor.Cancel(i, err)
+			return
+		}
+		c.metrics.Process(c.name, c.clock.Now().Sub(start), err)
 		if err != nil {
+			c.metrics.Cancel(c.name, err)
 			processor.Cancel(i, err)
 			return
 		}
-		out <- result
+		deliver(c.node, out, result)
+		c.metrics.Out(c.name)
 	}
 }
@@ -17,16 +17,20 @@ func ProcessBatch[Input, Output any](
 	maxDuration time.Duration,
 	processor Processor[[]Input, []Output],
 	in <-chan Input,
+	opts ...Option,
 ) <-chan Output {
+	c := newConfig("ProcessBatch", opts)
 	out := make(chan Output)
-	go func() {
+	connect(c, out, in)
+	goStage(ctx, c.name, c.kind, func() {
 		for {
-			if !processOneBatch(ctx, maxSize, maxDuration, processor, in, out) {
+			if !processOneBatch(ctx, c, maxSize, maxDuration, processor, in, out) {
 				break
 			}
 		}
 		close(out)
-	}()
+		c.closed()
+	})
 	return out
 }
 
@@ -39,17 +43,21 @@ func ProcessBatchConcurrently[Input, Output any](
 	maxDuration time.Duration,
 	processor Processor[[]Input, []Output],
 	in <-chan Input,
+	opts ...Option,
 ) <-chan Output {
+	c := newConfig("ProcessBatchConcurrently", opts)
 	// Create the out chan
 	out := make(chan Output)
-	go func() {
+	connect(c, out, in)
+	goStage(ctx, c.name, c.kind, func() {
 		// Perform Process concurrently times
 		sem := semaphore.New(concurrently)
+		c.node.limit(sem)
 		lctx, done := context.WithCancel(context.Background())
 		for !isDone(lctx) {
 			sem.Add(1)
 			go func() {
-				if !processOneBatch(ctx, maxSize, maxDuration, processor, in, out) {
+				if !processOneBatch(ctx, c, maxSize, maxDuration, processor, in, out) {
 					done()
 				}
 				sem.Done()
@@ -58,8 +66,9 @@ func ProcessBatchConcurrently[Input, Output any](
 		// Close the out chan after all of the Processors finish executing
 		sem.Wait()
 		close(out)
+		c.closed()
 		done() // Satisfy go-vet
-	}()
+	})
 	return out
 }
 
@@ -77,6 +86,7 @@ func isDone(ctx context.Context) bool {
 // It returns true if the in chan is still open.
 func processOneBatch[Input, Output any](
 	ctx context.Context,
+	c *config,
 	maxSize int,
 	maxDuration time.Duration,
 	processor Processor[[]Input, []Output],
@@ -84,22 +94,32 @@ func processOneBatch[Input, Output any](
 	out chan<- Output,
 ) (open bool) {
 	// Collect interfaces for batch processing
-	is, open := collect(ctx, maxSize, maxDuration, in)
+	is, first, open := collect(ctx, c, maxSize, maxDuration, in)
+	defer c.node.hold(-int64(len(is)))
 	if is != nil {
+		c.metrics.Batch(c.name, len(is))
 		select {
 		// Cancel all inputs during shutdown
 		case <-ctx.Done():
+			c.metrics.Cancel(c.name, ctx.Err())
 			processor.Cancel(is, ctx.Err())
 		// Otherwise Process the inputs
 		default:
+			c.metrics.QueueWait(c.name, c.clock.Now().Sub(first))
+			c.metrics.InFlight(c.name, len(is))
+			defer c.metrics.InFlight(c.name, -len(is))
+			start := c.clock.Now()
 			results, err := processor.Process(ctx, is)
+			c.metrics.Process(c.name, c.clock.Now().Sub(start), err)
 			if err != nil {
+				c.metrics.Cancel(c.name, err)
 				processor.Cancel(is, err)
 				return open
 			}
 			// Split the results back into interfaces
 			for _, result := range results {
-				out <- result
+				deliver(c.node, out, result)
+				c.metrics.Out(c.name)
 			}
 		}
 	}
@@ -312,7 +312,7 @@ func Test_processBatch(t *testing.T) {
 				case <-timeout:
 					break loop
 				default:
-					open = processOneBatch[int, int](ctx, tt.args.maxSize, tt.args.maxDuration, tt.args.processor, tt.args.in, tt.args.out)
+					open = processOneBatch[int, int](ctx, newConfig("ProcessBatch", nil), tt.args.maxSize, tt.args.maxDuration, tt.args.processor, tt.args.in, tt.args.out)
 					if !open {
 						break loop
 					}
@@ -0,0 +1,167 @@
+// Package prometheus exposes the metrics of pipeline stages to Prometheus.
+//
+// Example Usage
+//
+//	metrics, err := prometheus.New(nil)
+//	if err != nil {
+//		return err
+//	}
+//	http.Handle("/metrics", prometheus.Handler(nil))
+//
+//	// Every stage configured with the metrics is labelled by its name
+//	p = pipeline.Process(ctx, processor, p, pipeline.WithName("geocode"), pipeline.WithMetrics(metrics))
+package prometheus
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefixes the names of all metrics
+const Namespace = "pipeline"
+
+// stageLabel is the label that holds the name of the stage
+const stageLabel = "stage"
+
+// Metrics implements `pipeline.Metrics` with Prometheus counters, gauges and histograms labelled by stage name
+type Metrics struct {
+	in        *prometheus.CounterVec
+	out       *prometheus.CounterVec
+	processed *prometheus.HistogramVec
+	errors    *prometheus.CounterVec
+	canceled  *prometheus.CounterVec
+	inFlight  *prometheus.GaugeVec
+	batchSize *prometheus.HistogramVec
+	queueWait *prometheus.HistogramVec
+	dropped   *prometheus.CounterVec
+}
+
+// New creates Metrics and registers them with `reg`.
+// If `reg` is nil, they are registered with the `prometheus.DefaultRegisterer`.
+func New(reg prometheus.Registerer) (*Metrics, error) {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	labels := []string{stageLabel}
+	m := &Metrics{
+		in: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "items_in_total",
+			Help:      "Number of items received by the stage.",
+		}, labels),
+		out: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "items_out_total",
+			Help:      "Number of items sent by the stage.",
+		}, labels),
+		processed: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "process_duration_seconds",
+			Help:      "How long Processor.Process took.",
+			Buckets:   prometheus.DefBuckets,
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "process_errors_total",
+			Help:      "Number of errors returned by Processor.Process.",
+		}, labels),
+		canceled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "canceled_total",
+			Help:      "Number of times Processor.Cancel was called.",
+		}, labels),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "in_flight",
+			Help:      "Number of inputs the stage is processing.",
+		}, labels),
+		batchSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "batch_size",
+			Help:      "Number of items in each batch collected by the stage.",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 12),
+		}, labels),
+		queueWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "queue_wait_seconds",
+			Help:      "How long inputs waited after they were received before they were processed.",
+			Buckets:   prometheus.DefBuckets,
+		}, labels),
+		dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "dropped_total",
+			Help:      "Number of items dropped by the stage.",
+		}, labels),
+	}
+	for _, c := range []prometheus.Collector{
+		m.in,
+		m.out,
+		m.processed,
+		m.errors,
+		m.canceled,
+		m.inFlight,
+		m.batchSize,
+		m.queueWait,
+		m.dropped,
+	} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Handler serves the metrics gathered by `g` in the Prometheus exposition format.
+// If `g` is nil, the metrics of the `prometheus.DefaultGatherer` are served.
+func Handler(g prometheus.Gatherer) http.Handler {
+	if g == nil {
+		g = prometheus.DefaultGatherer
+	}
+	return promhttp.HandlerFor(g, promhttp.HandlerOpts{})
+}
+
+// In counts the items received by the stage
+func (m *Metrics) In(stage string) {
+	m.in.WithLabelValues(stage).Inc()
+}
+
+// Out counts the items sent by the stage
+func (m *Metrics) Out(stage string) {
+	m.out.WithLabelValues(stage).Inc()
+}
+
+// Process observes how long `Processor.Process` took and counts its errors
+func (m *Metrics) Process(stage string, duration time.Duration, err error) {
+	m.processed.WithLabelValues(stage).Observe(duration.Seconds())
+	if err != nil {
+		m.errors.WithLabelValues(stage).Inc()
+	}
+}
+
+// Cancel counts the calls to `Processor.Cancel`
+func (m *Metrics) Cancel(stage string, _ error) {
+	m.canceled.WithLabelValues(stage).Inc()
+}
+
+// InFlight adds delta to the number of inputs the stage is processing
+func (m *Metrics) InFlight(stage string, delta int) {
+	m.inFlight.WithLabelValues(stage).Add(float64(delta))
+}
+
+// Batch observes the size of a batch
+func (m *Metrics) Batch(stage string, size int) {
+	m.batchSize.WithLabelValues(stage).Observe(float64(size))
+}
+
+// QueueWait observes how long an input waited before it was processed
+func (m *Metrics) QueueWait(stage string, duration time.Duration) {
+	m.queueWait.WithLabelValues(stage).Observe(duration.Seconds())
+}
+
+// Drop counts the items dropped by the stage
+func (m *Metrics) Drop(stage string) {
+	m.dropped.WithLabelValues(stage).Inc()
+}
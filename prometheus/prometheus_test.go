@@ -0,0 +1,86 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	metrics, err := New(reg)
+	if err != nil {
+		t.Fatalf("New() = %s", err)
+	}
+
+	// Process 1-5, failing on 3, then sum them in batches of 2
+	p := pipeline.Emit(1, 2, 3, 4, 5)
+	p = pipeline.Process(context.Background(), pipeline.NewProcessor(func(_ context.Context, i int) (int, error) {
+		if i == 3 {
+			return 0, errors.New("3 is not allowed")
+		}
+		return i, nil
+	}, func(int, error) {}), p, pipeline.WithName("filter"), pipeline.WithMetrics(metrics))
+	p = pipeline.ProcessBatch(context.Background(), 2, time.Minute, pipeline.NewProcessor(func(_ context.Context, is []int) ([]int, error) {
+		var sum int
+		for _, i := range is {
+			sum += i
+		}
+		return []int{sum}, nil
+	}, func([]int, error) {}), p, pipeline.WithName("sum"), pipeline.WithMetrics(metrics))
+	pipeline.Drain(p)
+	// How many items a stage like BufferWithPolicy drops depends on the pace of its consumer
+	metrics.Drop("buffer")
+
+	// Scrape the metrics
+	server := httptest.NewServer(Handler(reg))
+	defer server.Close()
+	res, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET /metrics = %s", err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("reading /metrics = %s", err)
+	}
+
+	for _, want := range []string{
+		`pipeline_items_in_total{stage="filter"} 5`,
+		`pipeline_items_out_total{stage="filter"} 4`,
+		`pipeline_process_errors_total{stage="filter"} 1`,
+		`pipeline_canceled_total{stage="filter"} 1`,
+		`pipeline_process_duration_seconds_count{stage="filter"} 5`,
+		`pipeline_queue_wait_seconds_count{stage="filter"} 5`,
+		`pipeline_in_flight{stage="filter"} 0`,
+		`pipeline_items_in_total{stage="sum"} 4`,
+		`pipeline_items_out_total{stage="sum"} 2`,
+		`pipeline_batch_size_sum{stage="sum"} 4`,
+		`pipeline_batch_size_count{stage="sum"} 2`,
+		`pipeline_in_flight{stage="sum"} 0`,
+		`pipeline_dropped_total{stage="buffer"} 1`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("/metrics does not contain %q", want)
+		}
+	}
+}
+
+func TestNewRegistersOnce(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	if _, err := New(reg); err != nil {
+		t.Fatalf("New() = %s", err)
+	}
+	// The same metrics can't be registered twice
+	if _, err := New(reg); err == nil {
+		t.Error("New() = nil, want an error")
+	}
+}
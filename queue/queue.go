@@ -0,0 +1,414 @@
+// Package queue is a durable queue on local disk, backed by a write-ahead log.
+// It decouples the stages of a pipeline that produce items from the stages that process them: Sink persists the items of a pipeline,
+// and Source passes them on to another pipeline, even after a restart, until they are acked.
+package queue
+
+import (
+	"container/list"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/deliveryhero/pipeline/v2"
+	"github.com/deliveryhero/pipeline/v2/internal/segment"
+)
+
+// The kinds of records in the log, which are followed by the ID of the item
+const (
+	putRecord byte = 'p'
+	ackRecord byte = 'a'
+)
+
+// recordHeaderSize is the size of the kind and the ID at the start of a record
+const recordHeaderSize = 9
+
+// defaultSegmentSize is the size of the segments of the log, unless the queue is opened WithSegmentSize
+const defaultSegmentSize = 64 << 20
+
+// ErrClosed is returned when an item is put in a queue that is closed
+var ErrClosed = errors.New("queue: closed")
+
+// Option configures a Queue
+type Option func(*options)
+
+type options struct {
+	segmentSize int64
+	onError     func(error)
+}
+
+// WithSegmentSize sets the size after which the log starts a new segment.
+// Segments are removed once all of their items are acked, so smaller segments free up the disk sooner.
+func WithSegmentSize(size int64) Option {
+	return func(o *options) {
+		o.segmentSize = size
+	}
+}
+
+// WithErrorHandler calls `f` with the errors that can't be returned to the caller,
+// like a corrupt record found when the queue is opened, or an ack that can't be written.
+func WithErrorHandler(f func(error)) Option {
+	return func(o *options) {
+		o.onError = f
+	}
+}
+
+// Queue is a durable FIFO queue of items, that are encoded by a Codec and appended to a log in a directory.
+// Every item is passed on until it is acked, and acked items are removed from the log as it is compacted.
+// It is safe for concurrent use.
+type Queue[Item any] struct {
+	log   *segment.Log
+	codec pipeline.Codec[Item]
+	opts  options
+
+	mu     sync.Mutex
+	closed bool
+	nextID uint64
+	// items are the items that were not acked, by ID
+	items map[uint64]*item
+	// ready holds the IDs of the items that can be passed on, in order
+	ready *list.List
+	// segments counts the items put in each segment of the log
+	segments map[uint64]*segmentCount
+	// added is closed when an item is ready
+	added chan struct{}
+}
+
+// item is an item of the queue that was not acked
+type item struct {
+	id  uint64
+	pos segment.Position
+	// passed is true while the item was passed on and is waiting to be acked
+	passed bool
+}
+
+// segmentCount is the number of items put in a segment, and how many of them were not acked
+type segmentCount struct {
+	puts    int
+	unacked int
+}
+
+// Open opens the queue in `dir`, creating it if it doesn't exist.
+// The log is replayed, so the items that were not acked before the queue was closed or the process crashed are passed on again.
+func Open[Item any](dir string, codec pipeline.Codec[Item], opts ...Option) (*Queue[Item], error) {
+	o := options{
+		segmentSize: defaultSegmentSize,
+		onError:     func(error) {},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	log, err := segment.Open(dir, o.segmentSize)
+	if err != nil {
+		return nil, err
+	}
+	q := &Queue[Item]{
+		log:      log,
+		codec:    codec,
+		opts:     o,
+		nextID:   1,
+		items:    make(map[uint64]*item),
+		ready:    list.New(),
+		segments: make(map[uint64]*segmentCount),
+		added:    make(chan struct{}),
+	}
+	if err := q.replay(); err != nil {
+		log.Close()
+		return nil, err
+	}
+	if err := q.removeAcked(); err != nil {
+		log.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+// replay rebuilds the items that were not acked from the log
+func (q *Queue[Item]) replay() error {
+	r := q.log.NewReader(segment.Position{})
+	defer r.Close()
+	for {
+		record, pos, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err == segment.ErrCorrupt {
+			// Nothing after a corrupt record can be found, so the rest of the segment is lost
+			q.opts.onError(fmt.Errorf("queue: segment %d: %w", pos.Segment, err))
+			r.Skip()
+			continue
+		} else if err != nil {
+			return err
+		}
+		if len(record) < recordHeaderSize {
+			q.opts.onError(fmt.Errorf("queue: record at %+v: %w", pos, segment.ErrCorrupt))
+			continue
+		}
+		id := binary.BigEndian.Uint64(record[1:])
+		if id >= q.nextID {
+			q.nextID = id + 1
+		}
+		switch record[0] {
+		case putRecord:
+			// A put of the same item was copied forward by Compact
+			if i, ok := q.items[id]; ok {
+				q.segments[i.pos.Segment].unacked--
+				i.pos = pos
+			} else {
+				q.items[id] = &item{id: id, pos: pos}
+			}
+			q.count(pos.Segment).puts++
+			q.count(pos.Segment).unacked++
+		case ackRecord:
+			if i, ok := q.items[id]; ok {
+				delete(q.items, id)
+				q.segments[i.pos.Segment].unacked--
+			}
+		}
+	}
+	ids := make([]uint64, 0, len(q.items))
+	for id := range q.items {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		q.ready.PushBack(id)
+	}
+	return nil
+}
+
+// Put appends an item to the queue. It is written to the log, but not synced to disk, see Sync.
+func (q *Queue[Item]) Put(i Item) error {
+	data, err := q.codec.Encode(i)
+	if err != nil {
+		return err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrClosed
+	}
+	last := q.log.Last()
+	id := q.nextID
+	pos, err := q.log.Append(record(putRecord, id, data))
+	if err != nil {
+		return err
+	}
+	q.nextID++
+	q.items[id] = &item{id: id, pos: pos}
+	q.count(pos.Segment).puts++
+	q.count(pos.Segment).unacked++
+	q.ready.PushBack(id)
+	q.signal()
+	// Compact the log whenever it starts a new segment
+	if pos.Segment != last {
+		if err := q.compact(); err != nil {
+			q.opts.onError(fmt.Errorf("queue: compacting: %w", err))
+		}
+	}
+	return nil
+}
+
+// Sync syncs the items that were put in the queue to disk, so they survive a crash of the machine
+func (q *Queue[Item]) Sync() error {
+	return q.log.Sync()
+}
+
+// Len returns the number of items that were not acked
+func (q *Queue[Item]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// Compact removes the segments of the log whose items were all acked.
+// Segments in which less than half of the items are still waiting to be acked are removed as well, after those items are copied to the end of the log.
+// It is called whenever the log starts a new segment.
+func (q *Queue[Item]) Compact() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrClosed
+	}
+	return q.compact()
+}
+
+// Close closes the queue. Items that were passed on and not acked yet are passed on again when the queue is opened again.
+func (q *Queue[Item]) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	close(q.added)
+	return q.log.Close()
+}
+
+// next waits for an item to be ready, and returns it once it is marked as passed on.
+// It returns false once the context is canceled or the queue is closed.
+func (q *Queue[Item]) next(done <-chan struct{}) (uint64, Item, bool) {
+	var zero Item
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return 0, zero, false
+		}
+		front := q.ready.Front()
+		if front == nil {
+			added := q.added
+			q.mu.Unlock()
+			select {
+			case <-done:
+				return 0, zero, false
+			case <-added:
+			}
+			continue
+		}
+		id := q.ready.Remove(front).(uint64)
+		i := q.items[id]
+		i.passed = true
+		// The item is read while the queue is locked, so it can't be moved by Compact
+		record, err := q.log.Read(i.pos)
+		q.mu.Unlock()
+		if err == nil {
+			var it Item
+			if it, err = q.codec.Decode(record[recordHeaderSize:]); err == nil {
+				return id, it, true
+			}
+		}
+		// The item can't be passed on, so it is dropped rather than blocking the queue
+		q.opts.onError(fmt.Errorf("queue: item %d: %w", id, err))
+		q.ack(id)
+	}
+}
+
+// ack removes an item from the queue
+func (q *Queue[Item]) ack(id uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	i, ok := q.items[id]
+	if !ok || q.closed {
+		return
+	}
+	if _, err := q.log.Append(record(ackRecord, id, nil)); err != nil {
+		// The item is passed on again after a restart
+		q.opts.onError(fmt.Errorf("queue: acking item %d: %w", id, err))
+		return
+	}
+	delete(q.items, id)
+	q.segments[i.pos.Segment].unacked--
+	if err := q.removeAcked(); err != nil {
+		q.opts.onError(err)
+	}
+}
+
+// nack makes an item that was passed on ready to be passed on again, after the items that are already ready
+func (q *Queue[Item]) nack(id uint64) {
+	q.release(id, false)
+}
+
+// requeue makes an item that was taken from the queue but never sent ready to be passed on again, before the items that are already ready,
+// so it keeps its place in the order of the queue
+func (q *Queue[Item]) requeue(id uint64) {
+	q.release(id, true)
+}
+
+// release makes an item that was passed on ready to be passed on again, first or last
+func (q *Queue[Item]) release(id uint64, first bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	i, ok := q.items[id]
+	if !ok || !i.passed || q.closed {
+		return
+	}
+	i.passed = false
+	if first {
+		q.ready.PushFront(id)
+	} else {
+		q.ready.PushBack(id)
+	}
+	q.signal()
+}
+
+// signal wakes up the sources waiting for an item
+func (q *Queue[Item]) signal() {
+	close(q.added)
+	q.added = make(chan struct{})
+}
+
+// count returns the counts of a segment
+func (q *Queue[Item]) count(seg uint64) *segmentCount {
+	c, ok := q.segments[seg]
+	if !ok {
+		c = &segmentCount{}
+		q.segments[seg] = c
+	}
+	return c
+}
+
+// removeAcked removes the segments at the start of the log whose items were all acked.
+// The acks in a segment are always of items put in the same or an earlier segment, so they are never needed once it is removed.
+func (q *Queue[Item]) removeAcked() error {
+	first, last := q.log.First(), q.log.Last()
+	seg := first
+	for ; seg < last; seg++ {
+		if c, ok := q.segments[seg]; ok && c.unacked > 0 {
+			break
+		}
+		delete(q.segments, seg)
+	}
+	if seg == first {
+		return nil
+	}
+	return q.log.Remove(seg)
+}
+
+// compact removes the acked segments, then copies the items of the first segment forward while less than half of them are waiting to be acked
+func (q *Queue[Item]) compact() error {
+	if err := q.removeAcked(); err != nil {
+		return err
+	}
+	for {
+		first := q.log.First()
+		c := q.segments[first]
+		if first == q.log.Last() || c == nil || c.unacked*2 >= c.puts {
+			return nil
+		}
+		for _, i := range q.items {
+			if i.pos.Segment != first {
+				continue
+			}
+			record, err := q.log.Read(i.pos)
+			if err != nil {
+				return err
+			}
+			pos, err := q.log.Append(record)
+			if err != nil {
+				return err
+			}
+			c.unacked--
+			q.count(pos.Segment).puts++
+			q.count(pos.Segment).unacked++
+			i.pos = pos
+		}
+		// The copies must be on disk before the segment is removed
+		if err := q.log.Sync(); err != nil {
+			return err
+		}
+		if err := q.removeAcked(); err != nil {
+			return err
+		}
+	}
+}
+
+// record encodes a record of the log
+func record(kind byte, id uint64, data []byte) []byte {
+	r := make([]byte, recordHeaderSize+len(data))
+	r[0] = kind
+	binary.BigEndian.PutUint64(r[1:], id)
+	copy(r[recordHeaderSize:], data)
+	return r
+}
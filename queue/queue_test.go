@@ -0,0 +1,228 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline/v2"
+	"github.com/deliveryhero/pipeline/v2/pipelinetest"
+)
+
+// open opens the queue of ints in dir, failing the test if it can't
+func open(t *testing.T, dir string, opts ...Option) *Queue[int] {
+	t.Helper()
+	q, err := Open(dir, pipeline.JSONCodec[int](), opts...)
+	if err != nil {
+		t.Fatalf("Open() = %s", err)
+	}
+	return q
+}
+
+// put puts the items in the queue, failing the test if it can't
+func put(t *testing.T, q *Queue[int], is ...int) {
+	t.Helper()
+	for _, i := range is {
+		if err := q.Put(i); err != nil {
+			t.Fatalf("Put(%d) = %s", i, err)
+		}
+	}
+}
+
+// values returns the values of the envelopes
+func values(es []pipeline.Envelope[int]) []int {
+	vs := make([]int, len(es))
+	for i, e := range es {
+		vs[i] = e.Value
+	}
+	return vs
+}
+
+func TestQueue(t *testing.T) {
+	t.Parallel()
+
+	q := open(t, t.TempDir())
+	defer q.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := q.Sink(ctx, pipeline.Emit(1, 2, 3)); err != nil {
+		t.Fatalf("Sink() = %s", err)
+	}
+
+	source := q.Source(ctx)
+	es := pipelinetest.CollectN(t, source, 3, time.Second)
+	if vs := values(es); !reflect.DeepEqual(vs, []int{1, 2, 3}) {
+		t.Errorf("items = %v, want [1 2 3]", vs)
+	}
+
+	// Nacked items are passed on again after the items that are waiting
+	put(t, q, 4)
+	es[0].Ack()
+	es[1].Nack(errors.New("failed"))
+	es = pipelinetest.CollectN(t, source, 2, time.Second)
+	if vs := values(es); !reflect.DeepEqual(vs, []int{4, 2}) {
+		t.Errorf("items = %v, want [4 2]", vs)
+	}
+	if n := q.Len(); n != 3 {
+		t.Errorf("len = %d, want 3", n)
+	}
+}
+
+func TestQueueRecoversAfterCrash(t *testing.T) {
+	t.Parallel()
+
+	// The first queue is never closed, as if the process crashed
+	dir := t.TempDir()
+	q := open(t, dir)
+	put(t, q, 1, 2, 3, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	es := pipelinetest.CollectN(t, q.Source(ctx), 3, time.Second)
+	es[0].Ack()
+	es[2].Ack()
+	cancel()
+
+	// The items that were not acked are passed on again, in order
+	q = open(t, dir)
+	defer q.Close()
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	es = pipelinetest.CollectN(t, q.Source(ctx), 2, time.Second)
+	if vs := values(es); !reflect.DeepEqual(vs, []int{2, 4}) {
+		t.Errorf("items = %v, want [2 4]", vs)
+	}
+
+	// New items don't reuse the IDs of the old ones
+	es[0].Ack()
+	put(t, q, 5)
+	q.Close()
+	q = open(t, dir)
+	defer q.Close()
+	if es := pipelinetest.CollectN(t, q.Source(ctx), 2, time.Second); !reflect.DeepEqual(values(es), []int{4, 5}) {
+		t.Errorf("items = %v, want [4 5]", values(es))
+	}
+}
+
+func TestQueueRecoversFromTornWrite(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	q := open(t, dir)
+	put(t, q, 1, 2)
+	q.Close()
+
+	// The process crashed while writing a record
+	segments, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	f, err := os.OpenFile(segments[len(segments)-1], os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 1, 0, 'p', 0, 0})
+	f.Close()
+
+	q = open(t, dir)
+	defer q.Close()
+	put(t, q, 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if es := pipelinetest.CollectN(t, q.Source(ctx), 3, time.Second); !reflect.DeepEqual(values(es), []int{1, 2, 3}) {
+		t.Errorf("items = %v, want [1 2 3]", values(es))
+	}
+}
+
+func TestQueueCompaction(t *testing.T) {
+	t.Parallel()
+
+	// Every segment holds about 4 puts
+	dir := t.TempDir()
+	q := open(t, dir, WithSegmentSize(4*20))
+	defer q.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	put(t, q, 1, 2, 3, 4, 5, 6, 7, 8)
+	segments := func() int {
+		s, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+		return len(s)
+	}
+	before := segments()
+
+	// Acking everything but the first item leaves the first segment mostly acked
+	es := pipelinetest.CollectN(t, q.Source(ctx), 8, time.Second)
+	for _, e := range es[1:] {
+		e.Ack()
+	}
+	if err := q.Compact(); err != nil {
+		t.Fatalf("Compact() = %s", err)
+	}
+	if after := segments(); after >= before {
+		t.Errorf("segments = %d after compaction, want fewer than %d", after, before)
+	}
+
+	// The item that was not acked survives the compaction
+	cancel()
+	q.Close()
+	q = open(t, dir)
+	defer q.Close()
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	if es := pipelinetest.CollectN(t, q.Source(ctx), 1, time.Second); !reflect.DeepEqual(values(es), []int{1}) {
+		t.Errorf("items = %v, want [1]", values(es))
+	}
+	if n := q.Len(); n != 1 {
+		t.Errorf("len = %d, want 1", n)
+	}
+}
+
+func TestSinkCanceled(t *testing.T) {
+	t.Parallel()
+
+	q := open(t, t.TempDir())
+	defer q.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	in := make(chan int)
+	if err := q.Sink(ctx, in); !errors.Is(err, context.Canceled) {
+		t.Errorf("Sink() = %v, want %s", err, context.Canceled)
+	}
+	// The in channel is drained
+	in <- 1
+	close(in)
+}
+
+func TestSourceClosed(t *testing.T) {
+	t.Parallel()
+
+	q := open(t, t.TempDir())
+	source := q.Source(context.Background())
+	q.Close()
+	if es := pipelinetest.Collect(t, source, time.Second); len(es) != 0 {
+		t.Errorf("items = %v after the queue was closed", values(es))
+	}
+	if err := q.Put(1); !errors.Is(err, ErrClosed) {
+		t.Errorf("Put() = %v, want %s", err, ErrClosed)
+	}
+}
+
+func TestSourceCanceled(t *testing.T) {
+	t.Parallel()
+
+	q := open(t, t.TempDir())
+	defer q.Close()
+	put(t, q, 1, 2, 3)
+
+	// The source takes 1 from the queue and is canceled before it can send it
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if es := pipelinetest.Collect(t, q.Source(ctx), time.Second); len(es) != 0 {
+		t.Errorf("items = %v after the source was canceled", values(es))
+	}
+
+	// 1 keeps its place in the queue
+	es := pipelinetest.CollectN(t, q.Source(context.Background()), 3, time.Second)
+	if vs := values(es); !reflect.DeepEqual(vs, []int{1, 2, 3}) {
+		t.Errorf("items = %v, want [1 2 3]", vs)
+	}
+}
@@ -0,0 +1,71 @@
+package queue
+
+import (
+	"context"
+	"runtime/pprof"
+
+	"github.com/deliveryhero/pipeline/v2"
+)
+
+// Sink puts every item from `in <-chan Item` in the queue until the in channel is closed, then syncs the queue to disk.
+// The queue is synced whenever the in channel is empty, so the items of a buffered in channel are synced together.
+// If an item can't be put in the queue or the context is canceled, Sink returns the error,
+// and anything remaining in the in channel is drained without being put in the queue, so the upstream stages never block.
+func (q *Queue[Item]) Sink(ctx context.Context, in <-chan Item) error {
+	for {
+		select {
+		case <-ctx.Done():
+			go pipeline.Drain(in)
+			return context.Cause(ctx)
+		case i, open := <-in:
+			if !open {
+				return q.Sync()
+			}
+			err := q.Put(i)
+			if err == nil && len(in) == 0 {
+				err = q.Sync()
+			}
+			if err != nil {
+				go pipeline.Drain(in)
+				return err
+			}
+		}
+	}
+}
+
+// Source passes the items of the queue to the out channel in envelopes, in the order they were put in the queue.
+// It starts with the items that were not acked before the queue was opened, then passes on items as they are put in the queue.
+// Acking an envelope removes its item from the queue. Nacking it passes the item on again, after the items that are already waiting.
+// An item that is neither acked nor nacked is passed on again after the queue is opened again.
+// The out channel is closed once the context is canceled or the queue is closed. An item that was not sent by then keeps its place in the queue.
+func (q *Queue[Item]) Source(ctx context.Context) <-chan pipeline.Envelope[Item] {
+	out := make(chan pipeline.Envelope[Item])
+	go pprof.Do(ctx, pprof.Labels(pipeline.StageLabel, "Source", pipeline.KindLabel, "Queue"), func(context.Context) {
+		defer close(out)
+		for {
+			id, i, ok := q.next(ctx.Done())
+			if !ok {
+				return
+			}
+			e := pipeline.NewEnvelope(i, func(err error) {
+				if err != nil {
+					q.nack(id)
+				} else {
+					q.ack(id)
+				}
+			})
+			// An item that is not sent keeps its place in the queue, and the cancellation is preferred if out is also ready
+			if ctx.Err() != nil {
+				q.requeue(id)
+				return
+			}
+			select {
+			case <-ctx.Done():
+				q.requeue(id)
+				return
+			case out <- e:
+			}
+		}
+	})
+	return out
+}
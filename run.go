@@ -0,0 +1,139 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+)
+
+// Flow is a source and the stages chained to it. Nothing is started until the Pipeline it is passed to runs.
+// Create one with From, then chain stages to it with Then.
+type Flow[Item any] func(ctx context.Context, g *Graph) <-chan Item
+
+// From starts a Flow with a source named `name`, like Emitter or a consumer of a queue.
+// The source should stop and close its channel when the context is canceled.
+func From[Item any](name string, source func(ctx context.Context) <-chan Item) Flow[Item] {
+	return func(ctx context.Context, g *Graph) <-chan Item {
+		return Register(g, name, "Source", source(ctx))
+	}
+}
+
+// Then chains a stage named `name` to a Flow.
+// The stage must pass the options it is given on to a stage like Process, so that its items are counted in the Stats:
+//
+//	flow = pipeline.Then(flow, "double", func(ctx context.Context, in <-chan int, opts ...pipeline.Option) <-chan int {
+//		return pipeline.Process(ctx, doubler, in, opts...)
+//	})
+func Then[In, Out any](f Flow[In], name string, stage func(ctx context.Context, in <-chan In, opts ...Option) <-chan Out) Flow[Out] {
+	return func(ctx context.Context, g *Graph) <-chan Out {
+		return stage(ctx, f(ctx, g), WithName(name), WithGraph(g))
+	}
+}
+
+// Pipeline runs a Flow to completion and owns the context of its stages.
+// It is done when the last stage closes its out channel, which happens after the source is exhausted or the pipeline is stopped.
+type Pipeline struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	graph  *Graph
+	start  func()
+	once   sync.Once
+	done   chan struct{}
+	err    error
+}
+
+// New creates a Pipeline that runs the Flow with a context derived from `ctx`.
+// Everything the last stage of the Flow outputs is drained.
+func New[Item any](ctx context.Context, f Flow[Item]) *Pipeline {
+	ctx, cancel := context.WithCancel(ctx)
+	p := &Pipeline{
+		ctx:    ctx,
+		cancel: cancel,
+		graph:  NewGraph(),
+		done:   make(chan struct{}),
+	}
+	p.start = func() {
+		out := f(ctx, p.graph)
+		goStage(ctx, "Pipeline", "Pipeline", func() {
+			Drain(out)
+			// The pipeline only failed if it was stopped before it completed
+			p.err = context.Cause(ctx)
+			cancel()
+			close(p.done)
+		})
+	}
+	return p
+}
+
+// Run starts the pipeline, unless it is already running
+func (p *Pipeline) Run() {
+	p.once.Do(p.start)
+}
+
+// Wait runs the pipeline and blocks until it is done.
+// It returns the Stats of every stage, and the error of the context if it was canceled or the pipeline was stopped before it was done.
+func (p *Pipeline) Wait() (Stats, error) {
+	p.Run()
+	<-p.done
+	return p.Stats(), p.err
+}
+
+// Stop cancels the context of the pipeline, so the source stops and the stages cancel their remaining inputs.
+// Call Wait to wait for the pipeline to shut down.
+func (p *Pipeline) Stop() {
+	p.cancel()
+}
+
+// Stats returns the Stats of every stage of the pipeline so far
+func (p *Pipeline) Stats() Stats {
+	stats := p.graph.Stats()
+	s := Stats{Stages: make([]StageSummary, len(stats))}
+	for i, st := range stats {
+		s.Stages[i] = StageSummary{
+			Name:      st.Name,
+			Kind:      st.Kind,
+			Processed: st.In,
+			Emitted:   st.Out,
+			Failed:    st.Errors,
+			Canceled:  st.Canceled,
+			Dropped:   st.Dropped,
+		}
+	}
+	return s
+}
+
+// Graph returns the graph of the stages of the pipeline, which can serve their status or be watched for stalls
+func (p *Pipeline) Graph() *Graph {
+	return p.graph
+}
+
+// Stats summarizes what the stages of a Pipeline did
+type Stats struct {
+	// Stages are in the order they were chained, starting with the source
+	Stages []StageSummary `json:"stages"`
+}
+
+// StageSummary counts the items that went through a stage of a Pipeline
+type StageSummary struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	// Processed is the number of items the stage received
+	Processed int64 `json:"processed"`
+	// Emitted is the number of items the stage sent to its out channel
+	Emitted int64 `json:"emitted"`
+	// Failed is the number of items the stage failed to process
+	Failed int64 `json:"failed"`
+	// Canceled is the number of items the stage canceled, including the ones that failed
+	Canceled int64 `json:"canceled"`
+	// Dropped is the number of items the stage dropped, like BufferWithPolicy when its buffer is full
+	Dropped int64 `json:"dropped"`
+}
+
+// Stage returns the summary of the stage named `name`, if there is one
+func (s Stats) Stage(name string) (StageSummary, bool) {
+	for _, stage := range s.Stages {
+		if stage.Name == name {
+			return stage, true
+		}
+	}
+	return StageSummary{}, false
+}
@@ -0,0 +1,44 @@
+package pipeline_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/deliveryhero/pipeline/v2"
+)
+
+func ExampleNew() {
+	// Emit the numbers 1-10
+	flow := pipeline.From("numbers", func(context.Context) <-chan int {
+		return pipeline.Emit(1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+	})
+
+	// Then print the even numbers, and fail the odd ones
+	flow = pipeline.Then(flow, "print", func(ctx context.Context, in <-chan int, opts ...pipeline.Option) <-chan int {
+		return pipeline.Process(ctx, pipeline.NewProcessor(func(ctx context.Context, i int) (int, error) {
+			if i%2 != 0 {
+				return i, fmt.Errorf("%d is odd", i)
+			}
+			fmt.Printf("result: %d\n", i)
+			return i, nil
+		}, func(int, error) {}), in, opts...)
+	})
+
+	// Run the pipeline until all of the numbers are processed
+	stats, err := pipeline.New(context.Background(), flow).Wait()
+	if err != nil {
+		fmt.Printf("pipeline failed: %s\n", err)
+	}
+	for _, s := range stats.Stages {
+		fmt.Printf("%s processed %d, emitted %d, failed %d\n", s.Name, s.Processed, s.Emitted, s.Failed)
+	}
+
+	// Output:
+	// result: 2
+	// result: 4
+	// result: 6
+	// result: 8
+	// result: 10
+	// numbers processed 10, emitted 10, failed 0
+	// print processed 10, emitted 5, failed 5
+}
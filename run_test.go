@@ -0,0 +1,79 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline/v2/pipelinetest"
+)
+
+func TestPipeline(t *testing.T) {
+	t.Parallel()
+
+	processor := pipelinetest.NewProcessor(pipelinetest.FailWhen(func(i int) bool {
+		return i == 3
+	}, errors.New("three"), func(_ context.Context, i int) (int, error) {
+		return i, nil
+	}))
+	flow := From("numbers", func(context.Context) <-chan int {
+		return Emit(1, 2, 3, 4)
+	})
+	flow = Then(flow, "process", func(ctx context.Context, in <-chan int, opts ...Option) <-chan int {
+		return Process[int, int](ctx, processor, in, opts...)
+	})
+	batches := Then(flow, "collect", func(ctx context.Context, in <-chan int, opts ...Option) <-chan []int {
+		return Collect(ctx, 2, time.Second, in, opts...)
+	})
+
+	stats, err := New(context.Background(), batches).Wait()
+	if err != nil {
+		t.Errorf("err = %s, want nil", err)
+	}
+	want := []StageSummary{
+		{Name: "numbers", Kind: "Source", Processed: 4, Emitted: 4},
+		{Name: "process", Kind: "Process", Processed: 4, Emitted: 3, Failed: 1, Canceled: 1},
+		{Name: "collect", Kind: "Collect", Processed: 3, Emitted: 2},
+	}
+	if len(stats.Stages) != len(want) {
+		t.Fatalf("stats = %+v, want %+v", stats, want)
+	}
+	for i := range want {
+		if stats.Stages[i] != want[i] {
+			t.Errorf("stage %d = %+v, want %+v", i, stats.Stages[i], want[i])
+		}
+	}
+	if s, ok := stats.Stage("process"); !ok || s.Failed != 1 {
+		t.Errorf("stage process = %+v, %t", s, ok)
+	}
+}
+
+func TestPipelineStop(t *testing.T) {
+	t.Parallel()
+
+	var count int
+	p := New(context.Background(), Then(From("count", func(ctx context.Context) <-chan int {
+		return Emitter(ctx, func() int {
+			count++
+			return count
+		})
+	}), "process", func(ctx context.Context, in <-chan int, opts ...Option) <-chan int {
+		return Process[int, int](ctx, pipelinetest.Identity[int](), in, opts...)
+	}))
+	p.Run()
+	waitFor(t, func() bool {
+		s, _ := p.Stats().Stage("process")
+		return s.Emitted > 0
+	})
+
+	// The pipeline shuts down once it is stopped
+	p.Stop()
+	stats, err := p.Wait()
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want %s", err, context.Canceled)
+	}
+	if s, _ := stats.Stage("process"); s.Processed == 0 {
+		t.Errorf("stage process = %+v, want items processed", s)
+	}
+}
@@ -0,0 +1,63 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+)
+
+// Sample passes the latest item received from `in <-chan Item` to the out channel once every `interval`.
+// Nothing is passed to the out channel if no item was received during the interval.
+// When the in channel is closed, the latest item is passed to the out channel before it is closed.
+// If the context is canceled, the latest item is passed to the out channel and sampling is no longer applied.
+func Sample[Item any](ctx context.Context, interval time.Duration, in <-chan Item, opts ...Option) <-chan Item {
+	c := newConfig("Sample", opts)
+	out := make(chan Item)
+	connect(c, out, in)
+	goStage(ctx, c.name, c.kind, func() {
+		defer c.closed()
+		defer close(out)
+		var latest Item
+		var hasLatest bool
+		ticker := c.clock.NewTicker(interval)
+		defer ticker.Stop()
+	loop:
+		for {
+			select {
+			case <-ctx.Done():
+				break loop
+			case <-ticker.C():
+				if hasLatest {
+					deliver(c.node, out, latest)
+					c.node.hold(-1)
+					c.metrics.Out(c.name)
+					hasLatest = false
+				}
+			case i, open := <-in:
+				if !open {
+					break loop
+				}
+				c.metrics.In(c.name)
+				if !hasLatest {
+					c.node.hold(1)
+				}
+				latest, hasLatest = i, true
+			}
+		}
+		if hasLatest {
+			deliver(c.node, out, latest)
+			c.node.hold(-1)
+			c.metrics.Out(c.name)
+		}
+		// The context is canceled, so stop sampling
+		for {
+			i, open := receive(c.node, in)
+			if !open {
+				return
+			}
+			c.metrics.In(c.name)
+			deliver(c.node, out, i)
+			c.metrics.Out(c.name)
+		}
+	})
+	return out
+}
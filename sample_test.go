@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSample(t *testing.T) {
+	t.Parallel()
+
+	const maxTestDuration = time.Second
+	type args struct {
+		interval   time.Duration
+		ctxTimeout time.Duration
+		steps      []pingStep
+	}
+	type want struct {
+		out []ping
+	}
+	for _, test := range []struct {
+		name string
+		args args
+		want want
+	}{{
+		name: "passes the latest item every interval",
+		args: args{
+			interval:   maxTestDuration / 5,
+			ctxTimeout: maxTestDuration,
+			steps: []pingStep{
+				{ping: ping{"a", 1}},
+				{ping: ping{"a", 2}},
+				{ping: ping{"a", 3}},
+				{wait: maxTestDuration / 10 * 3, ping: ping{"a", 4}},
+				{ping: ping{"a", 5}},
+			},
+		},
+		want: want{
+			out: []ping{{"a", 3}, {"a", 5}},
+		},
+	}, {
+		name: "nothing is passed if nothing was received during the interval",
+		args: args{
+			interval:   maxTestDuration / 10,
+			ctxTimeout: maxTestDuration,
+			steps: []pingStep{
+				{ping: ping{"a", 1}},
+				{wait: maxTestDuration / 10 * 4, ping: ping{"a", 2}},
+			},
+		},
+		want: want{
+			out: []ping{{"a", 1}, {"a", 2}},
+		},
+	}, {
+		name: "sampling is not applied when the context is canceled",
+		args: args{
+			interval:   maxTestDuration,
+			ctxTimeout: maxTestDuration / 10,
+			steps: []pingStep{
+				{ping: ping{"a", 1}},
+				{ping: ping{"a", 2}},
+				{wait: maxTestDuration / 5, ping: ping{"a", 3}},
+				{ping: ping{"a", 4}},
+			},
+		},
+		want: want{
+			out: []ping{{"a", 2}, {"a", 3}, {"a", 4}},
+		},
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithTimeout(context.Background(), test.args.ctxTimeout)
+			defer cancel()
+
+			var outs []ping
+			for p := range Sample(ctx, test.args.interval, sendPings(test.args.steps)) {
+				outs = append(outs, p)
+			}
+
+			if !reflect.DeepEqual(test.want.out, outs) {
+				t.Errorf("out = %+v, want %+v", outs, test.want.out)
+			}
+		})
+	}
+}
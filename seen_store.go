@@ -0,0 +1,128 @@
+package pipeline
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/deliveryhero/pipeline/v2/internal/clock"
+)
+
+// SeenStore remembers the keys of items that have already been seen.
+// It is used by Distinct and Dedupe to detect duplicates, and must be safe for concurrent use.
+// Implement SeenStore to keep the seen keys somewhere other than memory, like a file or a database.
+type SeenStore[Key comparable] interface {
+	// Seen marks the key as seen and returns true if it had already been seen.
+	Seen(key Key) (bool, error)
+
+	// Forget removes the key, so it is not a duplicate the next time it is seen.
+	Forget(key Key) error
+}
+
+// NewSeenStore creates an in-memory SeenStore that remembers a key for `ttl` after it was last seen, or forever if `ttl` is 0.
+// Every time a key is seen again, it is remembered for another `ttl`, so a key that keeps being seen is never forgotten.
+// It holds up to `maxSize` keys, after which the least recently seen key is forgotten.
+// The store implements Snapshotter, so the keys can be checkpointed as long as they can be encoded as JSON.
+func NewSeenStore[Key comparable](ttl time.Duration, maxSize int) SeenStore[Key] {
+	return newSeenStore[Key](clock.Real(), ttl, maxSize)
+}
+
+// newSeenStore creates the store of NewSeenStore, telling the time with `clock`
+func newSeenStore[Key comparable](clock Clock, ttl time.Duration, maxSize int) *seenStore[Key] {
+	return &seenStore[Key]{
+		clock:   clock,
+		ttl:     ttl,
+		maxSize: maxSize,
+		keys:    list.New(),
+		index:   make(map[Key]*list.Element),
+	}
+}
+
+// seenKey is a key held by seenStore
+type seenKey[Key comparable] struct {
+	key Key
+	at  time.Time
+}
+
+// seenStore implements SeenStore as an expiring LRU set
+type seenStore[Key comparable] struct {
+	mu      sync.Mutex
+	clock   Clock
+	ttl     time.Duration
+	maxSize int
+	// keys are ordered from least to most recently seen
+	keys  *list.List
+	index map[Key]*list.Element
+}
+
+func (s *seenStore[Key]) Seen(key Key) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	// Forget everything that was last seen before the ttl
+	for el := s.keys.Front(); el != nil && s.ttl > 0 && !el.Value.(*seenKey[Key]).at.Add(s.ttl).After(now); el = s.keys.Front() {
+		s.remove(el)
+	}
+	if el, ok := s.index[key]; ok {
+		el.Value.(*seenKey[Key]).at = now
+		s.keys.MoveToBack(el)
+		return true, nil
+	}
+	// Make room for the new key
+	for s.keys.Len() > 0 && s.keys.Len() >= s.maxSize {
+		s.remove(s.keys.Front())
+	}
+	s.index[key] = s.keys.PushBack(&seenKey[Key]{key, now})
+	return false, nil
+}
+
+func (s *seenStore[Key]) Forget(key Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.index[key]; ok {
+		s.remove(el)
+	}
+	return nil
+}
+
+func (s *seenStore[Key]) remove(el *list.Element) {
+	delete(s.index, s.keys.Remove(el).(*seenKey[Key]).key)
+}
+
+// seenSnapshot is a key in the JSON snapshot of a seenStore
+type seenSnapshot[Key comparable] struct {
+	Key Key       `json:"key"`
+	At  time.Time `json:"at"`
+}
+
+// Snapshot encodes the keys from the least to the most recently seen as JSON
+func (s *seenStore[Key]) Snapshot() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]seenSnapshot[Key], 0, s.keys.Len())
+	for el := s.keys.Front(); el != nil; el = el.Next() {
+		k := el.Value.(*seenKey[Key])
+		keys = append(keys, seenSnapshot[Key]{k.key, k.at})
+	}
+	return json.Marshal(keys)
+}
+
+// Restore replaces the keys with the ones encoded by Snapshot
+func (s *seenStore[Key]) Restore(state []byte) error {
+	var keys []seenSnapshot[Key]
+	if err := json.Unmarshal(state, &keys); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys.Init()
+	s.index = make(map[Key]*list.Element, len(keys))
+	for _, k := range keys {
+		if el, ok := s.index[k.Key]; ok {
+			s.remove(el)
+		}
+		s.index[k.Key] = s.keys.PushBack(&seenKey[Key]{k.Key, k.At})
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSeenStore(t *testing.T) {
+	t.Parallel()
+
+	const maxTestDuration = time.Second
+	// step marks or forgets a key after waiting
+	type step struct {
+		wait   time.Duration
+		key    string
+		forget bool
+	}
+	type args struct {
+		ttl     time.Duration
+		maxSize int
+		steps   []step
+	}
+	type want struct {
+		seen []bool
+	}
+	for _, test := range []struct {
+		name string
+		args args
+		want want
+	}{{
+		name: "keys are seen after they are marked",
+		args: args{
+			ttl:     maxTestDuration,
+			maxSize: 10,
+			steps:   []step{{key: "a"}, {key: "b"}, {key: "a"}, {key: "b"}, {key: "c"}},
+		},
+		want: want{
+			seen: []bool{false, false, true, true, false},
+		},
+	}, {
+		name: "keys are not seen after the ttl",
+		args: args{
+			ttl:     maxTestDuration / 10,
+			maxSize: 10,
+			steps:   []step{{key: "a"}, {wait: maxTestDuration / 5, key: "a"}, {key: "a"}},
+		},
+		want: want{
+			seen: []bool{false, false, true},
+		},
+	}, {
+		name: "seeing a key again remembers it for another ttl",
+		args: args{
+			ttl:     maxTestDuration / 5,
+			maxSize: 10,
+			steps:   []step{{key: "a"}, {wait: maxTestDuration / 8, key: "a"}, {wait: maxTestDuration / 8, key: "a"}},
+		},
+		want: want{
+			seen: []bool{false, true, true},
+		},
+	}, {
+		name: "keys are remembered until they are forgotten without a ttl",
+		args: args{
+			maxSize: 10,
+			steps:   []step{{key: "a"}, {wait: maxTestDuration / 10, key: "a"}},
+		},
+		want: want{
+			seen: []bool{false, true},
+		},
+	}, {
+		name: "the least recently seen key is forgotten when the store is full",
+		args: args{
+			ttl:     maxTestDuration,
+			maxSize: 2,
+			steps:   []step{{key: "a"}, {key: "b"}, {key: "a"}, {key: "c"}, {key: "a"}, {key: "b"}},
+		},
+		want: want{
+			seen: []bool{false, false, true, false, true, false},
+		},
+	}, {
+		name: "forgotten keys are not seen",
+		args: args{
+			ttl:     maxTestDuration,
+			maxSize: 10,
+			steps:   []step{{key: "a"}, {key: "a", forget: true}, {key: "a"}},
+		},
+		want: want{
+			seen: []bool{false, false},
+		},
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := NewSeenStore[string](test.args.ttl, test.args.maxSize)
+			var seen []bool
+			for _, s := range test.args.steps {
+				time.Sleep(s.wait)
+				if s.forget {
+					if err := store.Forget(s.key); err != nil {
+						t.Fatalf("Forget(%s) = %s", s.key, err)
+					}
+					continue
+				}
+				ok, err := store.Seen(s.key)
+				if err != nil {
+					t.Fatalf("Seen(%s) = %s", s.key, err)
+				}
+				seen = append(seen, ok)
+			}
+
+			if !reflect.DeepEqual(test.want.seen, seen) {
+				t.Errorf("seen = %v, want %v", seen, test.want.seen)
+			}
+		})
+	}
+}
+
+func TestSeenStoreSnapshot(t *testing.T) {
+	t.Parallel()
+
+	store := NewSeenStore[string](time.Minute, 2)
+	for _, key := range []string{"a", "b", "c"} {
+		store.Seen(key)
+	}
+	state, err := store.(Snapshotter).Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot() = %s", err)
+	}
+
+	restored := NewSeenStore[string](time.Minute, 2)
+	restored.Seen("a")
+	if err := restored.(Snapshotter).Restore(state); err != nil {
+		t.Fatalf("Restore() = %s", err)
+	}
+	// Only the keys that were held when the snapshot was taken are seen
+	var seen []bool
+	for _, key := range []string{"b", "c", "a"} {
+		s, _ := restored.Seen(key)
+		seen = append(seen, s)
+	}
+	if want := []bool{true, true, false}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("seen = %v, want %v", seen, want)
+	}
+}
@@ -0,0 +1,44 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrDrainDeadlineExceeded is the cause of the stages context of GracefulShutdown
+// when the pipeline did not drain before the deadline
+var ErrDrainDeadlineExceeded = errors.New("drain deadline exceeded")
+
+// GracefulShutdown shuts a pipeline down in two phases when `ctx` is canceled, e.g. by a SIGTERM.
+// Pass the `sources` context to the stages that create items, like Emitter, and the `stages` context to the rest of the pipeline.
+//
+// When `ctx` is canceled, `sources` is canceled first, so no new items enter the pipeline.
+// The other stages keep processing the items in flight and in their buffers until the pipeline drains,
+// or until `drain` has passed. Then `stages` is canceled with the ErrDrainDeadlineExceeded cause, and the leftovers are canceled.
+// The deadline is measured with the clock set WithClock.
+// Call `cancel` to release the resources of the contexts once the pipeline is done.
+func GracefulShutdown(ctx context.Context, drain time.Duration, opts ...Option) (sources, stages context.Context, cancel context.CancelFunc) {
+	c := newConfig("GracefulShutdown", opts)
+	sources, cancelSources := context.WithCancel(ctx)
+	// The stages keep the values of ctx, but not its cancellation
+	stages, cancelStages := context.WithCancelCause(context.WithoutCancel(ctx))
+	goStage(ctx, c.name, c.kind, func() {
+		select {
+		case <-stages.Done():
+			return
+		case <-sources.Done():
+		}
+		deadline := c.clock.NewTimer(drain)
+		defer deadline.Stop()
+		select {
+		case <-stages.Done():
+		case <-deadline.C():
+			cancelStages(ErrDrainDeadlineExceeded)
+		}
+	})
+	return sources, stages, func() {
+		cancelSources()
+		cancelStages(context.Canceled)
+	}
+}
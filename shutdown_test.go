@@ -0,0 +1,77 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline/v2/pipelinetest"
+)
+
+func TestGracefulShutdown(t *testing.T) {
+	t.Parallel()
+
+	ctx, kill := context.WithCancel(context.Background())
+	sources, stages, cancel := GracefulShutdown(ctx, time.Second)
+	defer cancel()
+
+	// Every item takes a while to process
+	var count int
+	p := Emitter(sources, func() int {
+		count++
+		return count
+	})
+	processor := pipelinetest.NewProcessor(pipelinetest.Slow(10*time.Millisecond, func(_ context.Context, i int) (int, error) {
+		return i, nil
+	}))
+	p = ProcessConcurrently[int, int](stages, 5, processor, Buffer(10, p))
+
+	// The items in flight and in the buffer are processed after the pipeline is killed
+	pipelinetest.CollectN(t, p, 1, time.Second)
+	kill()
+	pipelinetest.Collect(t, p, time.Second)
+	if canceled := processor.Canceled(); len(canceled) != 0 {
+		t.Errorf("canceled %v, want nothing canceled", canceled)
+	}
+	if sources.Err() == nil {
+		t.Error("sources were not canceled")
+	}
+	if err := stages.Err(); err != nil {
+		t.Errorf("stages were canceled with %s while draining", err)
+	}
+}
+
+func TestGracefulShutdownDeadline(t *testing.T) {
+	t.Parallel()
+
+	ctx, kill := context.WithCancel(context.Background())
+	clock := pipelinetest.NewFakeClock(time.Now())
+	sources, stages, cancel := GracefulShutdown(ctx, time.Minute, WithClock(clock))
+	defer cancel()
+
+	// The item never finishes processing
+	in := make(chan int)
+	processor := pipelinetest.NewProcessor(pipelinetest.Block[int, int]())
+	p := Process[int, int](stages, processor, in)
+	in <- 1
+	close(in)
+	kill()
+
+	// It's canceled once the drain deadline passes
+	clock.BlockUntil(1)
+	if err := stages.Err(); err != nil {
+		t.Errorf("stages were canceled with %s before the deadline", err)
+	}
+	clock.Advance(time.Minute)
+	pipelinetest.Collect(t, p, time.Second)
+	if sources.Err() == nil {
+		t.Error("sources were not canceled")
+	}
+	if cause := context.Cause(stages); !errors.Is(cause, ErrDrainDeadlineExceeded) {
+		t.Errorf("stages were canceled by %v, want %s", cause, ErrDrainDeadlineExceeded)
+	}
+	if canceled := processor.Canceled(); len(canceled) != 1 {
+		t.Errorf("canceled %v, want [1]", canceled)
+	}
+}
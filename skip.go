@@ -0,0 +1,29 @@
+package pipeline
+
+import "context"
+
+// Skip drops the first `n` items from `in <-chan Item` and passes the rest to the out channel
+func Skip[Item any](n int, in <-chan Item, opts ...Option) <-chan Item {
+	c := newConfig("Skip", opts)
+	out := make(chan Item)
+	connect(c, out, in)
+	goStage(context.Background(), c.name, c.kind, func() {
+		defer c.closed()
+		defer close(out)
+		var skipped int
+		for {
+			i, open := receive(c.node, in)
+			if !open {
+				return
+			}
+			c.metrics.In(c.name)
+			if skipped < n {
+				skipped++
+				continue
+			}
+			deliver(c.node, out, i)
+			c.metrics.Out(c.name)
+		}
+	})
+	return out
+}
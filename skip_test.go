@@ -0,0 +1,55 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSkip(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		n  int
+		in []int
+	}
+	type want struct {
+		out []int
+	}
+	for _, test := range []struct {
+		name string
+		args args
+		want want
+	}{{
+		name: "drops the first n items",
+		args: args{
+			n:  2,
+			in: []int{1, 2, 3, 4, 5},
+		},
+		want: want{
+			out: []int{3, 4, 5},
+		},
+	}, {
+		name: "drops everything if in closes before n",
+		args: args{
+			n:  10,
+			in: []int{1, 2, 3},
+		},
+		want: want{
+			out: nil,
+		},
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var outs []int
+			for o := range Skip(test.args.n, Emit(test.args.in...)) {
+				outs = append(outs, o)
+			}
+
+			if !reflect.DeepEqual(test.want.out, outs) {
+				t.Errorf("out = %+v, want %+v", outs, test.want.out)
+			}
+		})
+	}
+}
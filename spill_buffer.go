@@ -0,0 +1,310 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/deliveryhero/pipeline/v2/internal/segment"
+)
+
+// spillSegmentSize is the size of the segment files SpillBuffer spills to
+const spillSegmentSize = 64 << 20
+
+// cursorFile is the name of the file that holds the position of the next spilled item to pass on
+const cursorFile = "cursor"
+
+// WithLost calls `f` with the error of every item SpillBuffer loses because it can't be spilled or read back,
+// or of the part of a segment file that is lost because it is corrupt.
+// It is only read by SpillBuffer, and any other stage it is given to panics when it is created.
+func WithLost(f func(err error)) Option {
+	return func(c *config) {
+		c.lost = f
+		c.stageOption("WithLost")
+	}
+}
+
+// SpillBuffer buffers up to `size` items from `in <-chan Item` in memory like Buffer, and spills the rest to segment files in `dir`,
+// so a downstream outage neither blocks the upstream stages nor runs out of memory.
+// Items are passed to the out channel in the order they were received, and the out channel is closed
+// after the in channel is closed and every item, including the spilled ones, is passed on.
+//
+// Spilled items are encoded with `codec`. A spilled item that can't be read back is lost and reported to `Metrics.Cancel`
+// and the func set WithLost, and so is an item that can't be spilled, e.g. because the disk is full.
+// Unless the buffer is configured WithLost or with Metrics that report cancellations, those items are lost silently.
+//
+// If the context is canceled, the items held in memory that were never spilled are passed on, and the rest of the in channel is spilled.
+// The spilled items that were not passed on are passed on first by the next SpillBuffer of `dir`, e.g. after a restart.
+// If the process crashes, the items held in memory are lost and a few spilled items may be passed on again.
+func SpillBuffer[Item any](ctx context.Context, size int, dir string, codec Codec[Item], in <-chan Item, opts ...Option) (<-chan Item, error) {
+	log, err := segment.Open(dir, spillSegmentSize)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := openCursor(filepath.Join(dir, cursorFile))
+	if err != nil {
+		log.Close()
+		return nil, err
+	}
+	if size < 1 {
+		size = 1
+	}
+	c := newConfig("SpillBuffer", opts, "WithLost")
+	out := make(chan Item)
+	connect(c, out, in)
+	s := &spillBuffer[Item]{
+		c:      c,
+		size:   size,
+		codec:  codec,
+		log:    log,
+		reader: log.NewReader(cursor.pos),
+		cursor: cursor,
+		// The items spilled before the buffer was opened are read back first
+		spilled: true,
+		resumed: log.End(),
+	}
+	goStage(ctx, c.name, c.kind, func() {
+		defer c.closed()
+		defer close(out)
+		defer s.close()
+		s.run(ctx, in, out)
+	})
+	return out, nil
+}
+
+// spilledItem is an item held in the memory of a SpillBuffer
+type spilledItem[Item any] struct {
+	item Item
+	// next is the position after the item, if it was read back from disk
+	next *segment.Position
+}
+
+// spillBuffer is the state of SpillBuffer.
+// The oldest items are held in memory and the newest are spilled to disk, so memory is refilled from disk as items are passed on.
+type spillBuffer[Item any] struct {
+	c      *config
+	size   int
+	codec  Codec[Item]
+	log    *segment.Log
+	reader *segment.Reader
+	cursor *cursor
+	memory []spilledItem[Item]
+	// spilled is true while there may be items on disk
+	spilled bool
+	// resumed is where the buffer started spilling, so items before it were spilled before the buffer was opened
+	resumed segment.Position
+	// held is the number of items held by the buffer since it was opened
+	held int64
+}
+
+func (s *spillBuffer[Item]) run(ctx context.Context, in <-chan Item, out chan<- Item) {
+	// Items that couldn't be read back or were left for the next buffer are no longer held
+	defer func() { s.hold(-s.held) }()
+	for ctx.Err() == nil {
+		s.refill()
+		if in == nil && len(s.memory) == 0 {
+			return
+		}
+		// A nil chan blocks forever, so nothing is sent while the memory is empty
+		var send chan<- Item
+		var next Item
+		if len(s.memory) > 0 {
+			send, next = out, s.memory[0].item
+			s.c.node.blockedOnSend(1)
+		}
+		if in != nil {
+			s.c.node.blockedOnReceive(1)
+		}
+		select {
+		case <-ctx.Done():
+			s.unblock(in, send)
+		case i, open := <-in:
+			s.unblock(in, send)
+			if !open {
+				in = nil
+				continue
+			}
+			s.c.metrics.In(s.c.name)
+			s.hold(1)
+			s.add(i)
+		case send <- next:
+			s.unblock(in, send)
+			s.passed()
+			s.hold(-1)
+			s.c.metrics.Out(s.c.name)
+		}
+	}
+
+	// The context is canceled, so only pass on the items that would be lost
+	for len(s.memory) > 0 && s.memory[0].next == nil {
+		deliver(s.c.node, out, s.memory[0].item)
+		s.passed()
+		s.hold(-1)
+		s.c.metrics.Out(s.c.name)
+	}
+	// and keep the rest for the next buffer, which the spilled items held in memory are left to as well.
+	// Nothing that was never spilled is held after a spilled item, see add, so the next buffer passes them on in order.
+	for in != nil {
+		i, open := receive(s.c.node, in)
+		if !open {
+			return
+		}
+		s.c.metrics.In(s.c.name)
+		if err := s.spill(i); err != nil {
+			s.lose(err)
+		}
+	}
+}
+
+// unblock records that the stage is no longer waiting to receive or send
+func (s *spillBuffer[Item]) unblock(in <-chan Item, send chan<- Item) {
+	if in != nil {
+		s.c.node.blockedOnReceive(-1)
+	}
+	if send != nil {
+		s.c.node.blockedOnSend(-1)
+	}
+}
+
+// add holds an item in memory, unless the memory is full or there are older items on disk.
+// It is spilled as well while memory holds items read back from disk, which are only on disk once the buffer is shut down,
+// so an item that was never spilled is never held after one that was.
+// An item that can't be spilled is lost, since holding it in memory would pass it on before the older items on disk.
+func (s *spillBuffer[Item]) add(i Item) {
+	if !s.spilled && len(s.memory) < s.size && (len(s.memory) == 0 || s.memory[len(s.memory)-1].next == nil) {
+		s.memory = append(s.memory, spilledItem[Item]{item: i})
+		return
+	}
+	if err := s.spill(i); err != nil {
+		s.hold(-1)
+		s.lose(err)
+	}
+}
+
+// spill appends an item to the disk
+func (s *spillBuffer[Item]) spill(i Item) error {
+	data, err := s.codec.Encode(i)
+	if err != nil {
+		return err
+	}
+	if _, err := s.log.Append(data); err != nil {
+		return err
+	}
+	s.spilled = true
+	return nil
+}
+
+// lose reports an item that is lost
+func (s *spillBuffer[Item]) lose(err error) {
+	s.c.metrics.Cancel(s.c.name, err)
+	if s.c.lost != nil {
+		s.c.lost(err)
+	}
+}
+
+// hold adds delta to the number of items held by the buffer
+func (s *spillBuffer[Item]) hold(delta int64) {
+	s.held += delta
+	s.c.node.hold(delta)
+}
+
+// refill reads spilled items back into memory until it is full or nothing is left on disk
+func (s *spillBuffer[Item]) refill() {
+	for s.spilled && len(s.memory) < s.size {
+		data, pos, err := s.reader.Next()
+		if err == io.EOF {
+			s.spilled = false
+			return
+		}
+		if err != nil {
+			// Nothing after a corrupt record can be found, so the rest of the segment is lost
+			s.lose(err)
+			s.reader.Skip()
+			continue
+		}
+		next := s.reader.Position()
+		i, err := s.codec.Decode(data)
+		if err != nil {
+			if !pos.Less(s.resumed) {
+				s.hold(-1)
+			}
+			s.lose(err)
+			continue
+		}
+		// Items spilled by this buffer were counted when they were received
+		if pos.Less(s.resumed) {
+			s.hold(1)
+		}
+		s.memory = append(s.memory, spilledItem[Item]{item: i, next: &next})
+	}
+}
+
+// passed removes the first item from memory once it was passed on, moving the cursor past it if it was spilled
+func (s *spillBuffer[Item]) passed() {
+	first := s.memory[0]
+	s.memory[0] = spilledItem[Item]{}
+	s.memory = s.memory[1:]
+	if first.next == nil {
+		return
+	}
+	if err := s.cursor.write(*first.next); err != nil {
+		// The item may be passed on again after a restart
+		return
+	}
+	// The segments before the cursor were passed on
+	_ = s.log.Remove(first.next.Segment)
+}
+
+func (s *spillBuffer[Item]) close() {
+	s.reader.Close()
+	s.cursor.close()
+	s.log.Close()
+}
+
+// cursor is a file holding a position in a segment log, followed by a checksum
+type cursor struct {
+	f   *os.File
+	pos segment.Position
+}
+
+// cursorSize is the size of the segment, offset and checksum in a cursor file
+const cursorSize = 20
+
+// openCursor opens the cursor file at `path`.
+// A cursor that is missing or corrupt is at the start of the log.
+func openCursor(path string) (*cursor, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	c := &cursor{f: f}
+	buf := make([]byte, cursorSize)
+	if _, err := f.ReadAt(buf, 0); err == nil && crc32.ChecksumIEEE(buf[:16]) == binary.BigEndian.Uint32(buf[16:]) {
+		c.pos = segment.Position{
+			Segment: binary.BigEndian.Uint64(buf),
+			Offset:  int64(binary.BigEndian.Uint64(buf[8:])),
+		}
+	}
+	return c, nil
+}
+
+// write moves the cursor. It is synced to disk when the cursor is closed.
+func (c *cursor) write(pos segment.Position) error {
+	buf := make([]byte, cursorSize)
+	binary.BigEndian.PutUint64(buf, pos.Segment)
+	binary.BigEndian.PutUint64(buf[8:], uint64(pos.Offset))
+	binary.BigEndian.PutUint32(buf[16:], crc32.ChecksumIEEE(buf[:16]))
+	if _, err := c.f.WriteAt(buf, 0); err != nil {
+		return err
+	}
+	c.pos = pos
+	return nil
+}
+
+func (c *cursor) close() {
+	c.f.Sync()
+	c.f.Close()
+}
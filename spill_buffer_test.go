@@ -0,0 +1,291 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline/v2/pipelinetest"
+)
+
+// sendAll sends the items to in, failing the test if they are not all received within a second
+func sendAll[Item any](t *testing.T, in chan<- Item, is ...Item) {
+	t.Helper()
+	for _, i := range is {
+		select {
+		case in <- i:
+		case <-time.After(time.Second):
+			t.Fatalf("%v was not received", i)
+		}
+	}
+}
+
+func TestSpillBuffer(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	g := NewGraph()
+	in := make(chan int)
+	out, err := SpillBuffer(context.Background(), 2, dir, JSONCodec[int](), in, WithGraph(g))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing reads the out channel, so everything after the first two items is spilled
+	sendAll(t, in, 1, 2, 3, 4, 5, 6)
+	waitFor(t, func() bool {
+		return g.Status()[0].Held == 6
+	})
+	if segments, _ := filepath.Glob(filepath.Join(dir, "*.seg")); len(segments) != 1 {
+		t.Errorf("segments = %v, want 1", segments)
+	}
+
+	// The items are passed on in order, including the ones received while the spilled ones are passed on
+	if is := pipelinetest.CollectN(t, out, 3, time.Second); !reflect.DeepEqual(is, []int{1, 2, 3}) {
+		t.Errorf("items = %v, want [1 2 3]", is)
+	}
+	sendAll(t, in, 7)
+	close(in)
+	if is := pipelinetest.Collect(t, out, time.Second); !reflect.DeepEqual(is, []int{4, 5, 6, 7}) {
+		t.Errorf("items = %v, want [4 5 6 7]", is)
+	}
+	if held := g.Status()[0].Held; held != 0 {
+		t.Errorf("held = %d, want 0", held)
+	}
+}
+
+func TestSpillBufferResumesAfterRestart(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan int)
+	out, err := SpillBuffer(ctx, 1, dir, JSONCodec[int](), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendAll(t, in, 1, 2, 3, 4, 5)
+	if is := pipelinetest.CollectN(t, out, 1, time.Second); !reflect.DeepEqual(is, []int{1}) {
+		t.Errorf("items = %v, want [1]", is)
+	}
+
+	// The spilled items are left on disk when the buffer is shut down, along with the rest of the in channel
+	cancel()
+	sendAll(t, in, 6)
+	close(in)
+	if is := pipelinetest.Collect(t, out, time.Second); len(is) != 0 {
+		t.Errorf("items = %v after the buffer was shut down", is)
+	}
+
+	// and passed on by the next buffer first
+	in = make(chan int)
+	out, err = SpillBuffer(context.Background(), 1, dir, JSONCodec[int](), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendAll(t, in, 7)
+	close(in)
+	if is := pipelinetest.Collect(t, out, time.Second); !reflect.DeepEqual(is, []int{2, 3, 4, 5, 6, 7}) {
+		t.Errorf("items = %v, want [2 3 4 5 6 7]", is)
+	}
+}
+
+func TestSpillBufferPassesOnItemsHeldInMemory(t *testing.T) {
+	t.Parallel()
+
+	// Items that were never spilled are passed on when the buffer is shut down
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan int)
+	out, err := SpillBuffer(ctx, 3, t.TempDir(), JSONCodec[int](), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendAll(t, in, 1, 2)
+	cancel()
+	close(in)
+	if is := pipelinetest.Collect(t, out, time.Second); !reflect.DeepEqual(is, []int{1, 2}) {
+		t.Errorf("items = %v, want [1 2]", is)
+	}
+}
+
+func TestSpillBufferSpillsItemsAfterSpilledOnes(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan int)
+	out, err := SpillBuffer(ctx, 2, dir, JSONCodec[int](), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 3 is spilled and read back, then 4 is spilled after it rather than held in memory
+	sendAll(t, in, 1, 2, 3)
+	if is := pipelinetest.CollectN(t, out, 2, time.Second); !reflect.DeepEqual(is, []int{1, 2}) {
+		t.Errorf("items = %v, want [1 2]", is)
+	}
+	sendAll(t, in, 4)
+
+	// Nothing is lost when the buffer is shut down, and the items stay in order
+	cancel()
+	close(in)
+	is := pipelinetest.Collect(t, out, time.Second)
+	in = make(chan int)
+	close(in)
+	out, err = SpillBuffer(context.Background(), 2, dir, JSONCodec[int](), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if is = append(is, pipelinetest.Collect(t, out, time.Second)...); !reflect.DeepEqual(is, []int{3, 4}) {
+		t.Errorf("items = %v, want [3 4]", is)
+	}
+}
+
+func TestSpillBufferKeepsOrderAcrossRestarts(t *testing.T) {
+	t.Parallel()
+
+	// restart runs a SpillBuffer of dir that receives the items, and returns what it passes on before it is shut down
+	dir := t.TempDir()
+	restart := func(size int, is ...int) []int {
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan int)
+		out, err := SpillBuffer(ctx, size, dir, JSONCodec[int](), in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sendAll(t, in, is...)
+		cancel()
+		close(in)
+		return pipelinetest.Collect(t, out, time.Second)
+	}
+
+	// 1 is passed on and 2 is left on disk
+	if is := restart(1, 1, 2); !reflect.DeepEqual(is, []int{1}) {
+		t.Errorf("items = %v, want [1]", is)
+	}
+	// 2 is read back, and 5 no longer fits in memory after 3 and 4
+	if is := restart(3, 3, 4, 5); len(is) != 0 {
+		t.Errorf("items = %v, want none", is)
+	}
+	in := make(chan int)
+	close(in)
+	out, err := SpillBuffer(context.Background(), 3, dir, JSONCodec[int](), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if is := pipelinetest.Collect(t, out, time.Second); !reflect.DeepEqual(is, []int{2, 3, 4, 5}) {
+		t.Errorf("items = %v, want [2 3 4 5]", is)
+	}
+}
+
+// failingCodec fails to decode one item
+type failingCodec struct {
+	Codec[int]
+}
+
+var errDecode = errors.New("decode")
+
+func (c failingCodec) Decode(data []byte) (int, error) {
+	i, err := c.Codec.Decode(data)
+	if i == 2 {
+		return 0, errDecode
+	}
+	return i, err
+}
+
+func TestSpillBufferReportsLostItems(t *testing.T) {
+	t.Parallel()
+
+	in := make(chan int)
+	m := newMockMetrics()
+	var lost []error
+	out, err := SpillBuffer(context.Background(), 1, t.TempDir(), failingCodec{JSONCodec[int]()}, in, WithMetrics(m), WithLost(func(err error) {
+		lost = append(lost, err)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendAll(t, in, 1, 2, 3)
+	close(in)
+	if is := pipelinetest.Collect(t, out, time.Second); !reflect.DeepEqual(is, []int{1, 3}) {
+		t.Errorf("items = %v, want [1 3]", is)
+	}
+	if len(lost) != 1 || !errors.Is(lost[0], errDecode) {
+		t.Errorf("lost = %v, want [%s]", lost, errDecode)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if canceled := m.canceled["SpillBuffer"]; canceled != 1 {
+		t.Errorf("canceled = %d, want 1", canceled)
+	}
+}
+
+// unspillableCodec fails to encode one item
+type unspillableCodec struct {
+	Codec[int]
+}
+
+var errEncode = errors.New("encode")
+
+func (c unspillableCodec) Encode(i int) ([]byte, error) {
+	if i == 3 {
+		return nil, errEncode
+	}
+	return c.Codec.Encode(i)
+}
+
+func TestSpillBufferReportsUnspilledItems(t *testing.T) {
+	t.Parallel()
+
+	in := make(chan int)
+	g := NewGraph()
+	m := newMockMetrics()
+	out, err := SpillBuffer(context.Background(), 1, t.TempDir(), unspillableCodec{JSONCodec[int]()}, in, WithMetrics(m), WithGraph(g))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 3 can't be spilled after 2, so it is lost rather than passed on before it
+	sendAll(t, in, 1, 2, 3, 4)
+	close(in)
+	if is := pipelinetest.Collect(t, out, time.Second); !reflect.DeepEqual(is, []int{1, 2, 4}) {
+		t.Errorf("items = %v, want [1 2 4]", is)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if canceled := m.canceled["SpillBuffer"]; canceled != 1 {
+		t.Errorf("canceled = %d, want 1", canceled)
+	}
+	if held := g.Status()[0].Held; held != 0 {
+		t.Errorf("held = %d, want 0", held)
+	}
+}
+
+func TestSpillBufferFailsToOpen(t *testing.T) {
+	t.Parallel()
+
+	// The directory is a file
+	dir := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(dir, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SpillBuffer(context.Background(), 1, dir, JSONCodec[int](), make(chan int)); err == nil {
+		t.Error("SpillBuffer() succeeded")
+	}
+}
+
+func TestWithLostOfAnotherStage(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Buffer did not panic")
+		}
+	}()
+	// Only SpillBuffer loses items
+	Buffer(1, make(chan int), WithLost(func(error) {}))
+}
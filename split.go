@@ -1,15 +1,26 @@
 package pipeline
 
+import "context"
+
 // Split takes an interface from Collect and splits it back out into individual elements
-func Split[Item any](in <-chan []Item) <-chan Item {
+func Split[Item any](in <-chan []Item, opts ...Option) <-chan Item {
+	c := newConfig("Split", opts)
 	out := make(chan Item)
-	go func() {
+	connect(c, out, in)
+	goStage(context.Background(), c.name, c.kind, func() {
+		defer c.closed()
 		defer close(out)
-		for is := range in {
+		for {
+			is, open := receive(c.node, in)
+			if !open {
+				return
+			}
+			c.metrics.In(c.name)
 			for _, i := range is {
-				out <- i
+				deliver(c.node, out, i)
+				c.metrics.Out(c.name)
 			}
 		}
-	}()
+	})
 	return out
 }
@@ -0,0 +1,81 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// StageStatus is a snapshot of what a stage in a Graph is doing
+type StageStatus struct {
+	StageStats
+	// Buffered is the number of items waiting in the out channel of the stage, like the buffer of Buffer.
+	Buffered int `json:"buffered"`
+	// BufferSize is the capacity of the out channel of the stage.
+	BufferSize int `json:"bufferSize"`
+	// Concurrent is the number of inputs a concurrent stage like ProcessConcurrently is processing.
+	Concurrent int `json:"concurrent"`
+	// Concurrency is the maximum number of inputs a concurrent stage can process at once.
+	Concurrency int `json:"concurrency"`
+	// BlockedOnReceive is the number of goroutines of the stage waiting to receive an item.
+	BlockedOnReceive int64 `json:"blockedOnReceive"`
+	// BlockedOnSend is the number of goroutines of the stage waiting to send an item.
+	BlockedOnSend int64 `json:"blockedOnSend"`
+	// Held is the number of items the stage holds until it passes them on, like the partial batch of Collect.
+	Held int64 `json:"held"`
+	// Paused is true while the stage is paused by a Valve, see Pausable.
+	Paused bool `json:"paused"`
+	// LastActivity is the last time the stage received, processed, canceled or sent an item, or nil if it has done none of that yet.
+	LastActivity *time.Time `json:"lastActivity,omitempty"`
+}
+
+// Status returns what every stage is doing in the order they were added to the graph
+func (g *Graph) Status() []StageStatus {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	status := make([]StageStatus, len(g.nodes))
+	for i, n := range g.nodes {
+		status[i] = n.status()
+	}
+	return status
+}
+
+// ServeHTTP responds with the status of every stage as JSON.
+// Use it to find out which stage is blocking a pipeline that has stalled.
+func (g *Graph) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(g.Status()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (n *node) status() StageStatus {
+	s := StageStatus{
+		StageStats:       n.stats(),
+		BlockedOnReceive: atomic.LoadInt64(&n.receiving),
+		BlockedOnSend:    atomic.LoadInt64(&n.sending),
+		Held:             atomic.LoadInt64(&n.held),
+		Paused:           atomic.LoadInt64(&n.paused) == 1,
+	}
+	if last := atomic.LoadInt64(&n.lastActivity); last != 0 {
+		at := time.Unix(0, last)
+		s.LastActivity = &at
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.out != nil {
+		out := reflect.ValueOf(n.out)
+		s.Buffered, s.BufferSize = out.Len(), out.Cap()
+	}
+	if n.sem != nil {
+		s.Concurrent, s.Concurrency = len(n.sem), cap(n.sem)
+	}
+	return s
+}
@@ -0,0 +1,114 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitFor waits up to a second for ok to return true
+func waitFor(t *testing.T, ok func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if ok() {
+			return
+		}
+	}
+	t.Fatal("timed out")
+}
+
+func TestGraphStatus(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Build a pipeline that is never read from
+	g := NewGraph()
+	p := Buffer(2, Emit(1, 2, 3, 4, 5, 6), WithName("buffer"), WithGraph(g))
+	p = ProcessConcurrently(ctx, 2, NewProcessor(func(ctx context.Context, i int) (int, error) {
+		if i > 1 {
+			<-ctx.Done()
+		}
+		return i, ctx.Err()
+	}, func(int, error) {}), p, WithName("process"), WithGraph(g))
+	waitFor(t, func() bool {
+		s := g.Status()
+		return s[0].BlockedOnSend == 1 && s[1].Concurrent == 2 && s[1].BlockedOnSend == 1
+	})
+
+	status := g.Status()
+	buffer, process := status[0], status[1]
+	if buffer.Buffered != 2 || buffer.BufferSize != 2 {
+		t.Errorf("buffer buffered %d of %d, want 2 of 2", buffer.Buffered, buffer.BufferSize)
+	}
+	if buffer.LastActivity == nil {
+		t.Error("buffer has no last activity")
+	}
+	if process.Concurrency != 2 {
+		t.Errorf("process concurrency = %d, want 2", process.Concurrency)
+	}
+	if process.InFlight != 2 {
+		t.Errorf("process in flight = %d, want 2", process.InFlight)
+	}
+	// It's waiting for room to process the next input, not for the input
+	if process.BlockedOnReceive != 0 {
+		t.Errorf("process blocked on receive = %d, want 0", process.BlockedOnReceive)
+	}
+
+	// Reading from the pipeline unblocks it
+	cancel()
+	Drain(p)
+	waitFor(t, func() bool {
+		s := g.Status()
+		return s[0].BlockedOnSend == 0 && s[1].BlockedOnSend == 0 && s[1].InFlight == 0
+	})
+}
+
+func TestGraphServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	g := NewGraph()
+	Drain(Process[int, int](context.Background(), &mockProcessor[int]{}, Emit(1, 2, 3), WithName("process"), WithGraph(g)))
+
+	// The status is served as JSON
+	res := httptest.NewRecorder()
+	g.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+	if res.Code != http.StatusOK {
+		t.Fatalf("status code = %d, want %d", res.Code, http.StatusOK)
+	}
+	var status []StageStatus
+	if err := json.Unmarshal(res.Body.Bytes(), &status); err != nil {
+		t.Fatalf("could not decode %s: %s", res.Body, err)
+	}
+	if len(status) != 1 || status[0].Name != "process" || status[0].Out != 3 {
+		t.Errorf("status = %+v", status)
+	}
+
+	// Only GET is allowed
+	res = httptest.NewRecorder()
+	g.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/", nil))
+	if res.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status code = %d, want %d", res.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestGraphServeHTTPIdle(t *testing.T) {
+	t.Parallel()
+
+	g := NewGraph()
+	in := make(chan int)
+	defer close(in)
+	Process[int, int](context.Background(), &mockProcessor[int]{}, in, WithName("process"), WithGraph(g))
+
+	// A stage that did nothing yet has no last activity
+	res := httptest.NewRecorder()
+	g.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+	if strings.Contains(res.Body.String(), "lastActivity") {
+		t.Errorf("body = %s, want no lastActivity", res.Body)
+	}
+}
@@ -0,0 +1,100 @@
+package pipeline
+
+import "context"
+
+// Take passes the first `n` items from `in <-chan Item` to the out channel, then closes the out channel
+// without waiting for another item. See TakeWhile for what happens to the upstream stages after that.
+func Take[Item any](ctx context.Context, n int, in <-chan Item, opts ...Option) <-chan Item {
+	return takeWhile(ctx, func() {}, newConfig("Take", opts), max(n, 0), always[Item], in)
+}
+
+// Limit passes the first `n` items of the upstream stages created by `source` to the out channel, like Take.
+// `source` is called with a context derived from ctx, which Limit cancels as soon as it has taken `n` items, before receiving another one,
+// so sources that never close, like Emitter, stop producing items that are no longer needed.
+func Limit[Item any](ctx context.Context, n int, source func(ctx context.Context) <-chan Item, opts ...Option) <-chan Item {
+	ctx, cancel := context.WithCancel(ctx)
+	return takeWhile(ctx, cancel, newConfig("Limit", opts), max(n, 0), always[Item], source(ctx))
+}
+
+// TakeWhile passes the items from `in <-chan Item` to the out channel until `ok` returns false, then closes the out channel.
+// The item `ok` returned false for is dropped.
+// Once the out channel is closed, everything remaining in the in channel is drained until it is closed, so the upstream stages never block.
+// Upstream stages that never close, like Emitter, must be stopped by canceling their context, see Limit.
+// If the context is canceled, the out channel is closed and the in channel is drained as well.
+func TakeWhile[Item any](ctx context.Context, ok func(Item) bool, in <-chan Item, opts ...Option) <-chan Item {
+	return takeWhile(ctx, func() {}, newConfig("TakeWhile", opts), -1, ok, in)
+}
+
+// TakeUntil passes the items from `in <-chan Item` to the out channel until `signal` receives a value or is closed,
+// then closes the out channel. See TakeWhile for what happens to the upstream stages after that.
+func TakeUntil[Item, Signal any](ctx context.Context, signal <-chan Signal, in <-chan Item, opts ...Option) <-chan Item {
+	c := newConfig("TakeUntil", opts)
+	out := make(chan Item)
+	connect(c, out, in)
+	goStage(ctx, c.name, c.kind, func() {
+		defer c.closed()
+		defer Drain(in)
+		defer close(out)
+		for {
+			c.node.blockedOnReceive(1)
+			select {
+			case <-ctx.Done():
+				c.node.blockedOnReceive(-1)
+				return
+			case <-signal:
+				c.node.blockedOnReceive(-1)
+				return
+			case i, open := <-in:
+				c.node.blockedOnReceive(-1)
+				if !open {
+					return
+				}
+				c.metrics.In(c.name)
+				c.node.blockedOnSend(1)
+				select {
+				case <-ctx.Done():
+					c.node.blockedOnSend(-1)
+					return
+				case <-signal:
+					c.node.blockedOnSend(-1)
+					return
+				case out <- i:
+					c.node.blockedOnSend(-1)
+					c.metrics.Out(c.name)
+				}
+			}
+		}
+	})
+	return out
+}
+
+// takeWhile passes the items from in to the out channel until ok returns false or `limit` items were passed on, then calls cancel and drains in.
+// A negative limit passes on items until ok returns false.
+func takeWhile[Item any](ctx context.Context, cancel context.CancelFunc, c *config, limit int, ok func(Item) bool, in <-chan Item) <-chan Item {
+	out := make(chan Item)
+	connect(c, out, in)
+	goStage(ctx, c.name, c.kind, func() {
+		defer c.closed()
+		defer Drain(in)
+		defer cancel()
+		defer close(out)
+		// Stop as soon as the limit is reached, rather than waiting for an item that is not taken
+		for taken := 0; limit < 0 || taken < limit; taken++ {
+			i, open, received := receiveOrDone(ctx, c.node, in)
+			if !received || !open {
+				return
+			}
+			c.metrics.In(c.name)
+			if !ok(i) || !send(ctx, c.node, i, out) {
+				return
+			}
+			c.metrics.Out(c.name)
+		}
+	})
+	return out
+}
+
+// always is ok for every item
+func always[Item any](Item) bool {
+	return true
+}
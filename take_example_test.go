@@ -0,0 +1,32 @@
+package pipeline_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/deliveryhero/pipeline/v2"
+)
+
+func ExampleLimit() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Only process the first 3 numbers, then stop the emitter
+	p := pipeline.Limit(ctx, 3, func(ctx context.Context) <-chan int {
+		// Emit numbers until ctx is canceled
+		var count int
+		return pipeline.Emitter(ctx, func() int {
+			count++
+			return count
+		})
+	})
+
+	for result := range p {
+		fmt.Printf("result: %d\n", result)
+	}
+
+	// Output:
+	// result: 1
+	// result: 2
+	// result: 3
+}
@@ -0,0 +1,201 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline/v2/pipelinetest"
+)
+
+func TestTake(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		n  int
+		in []int
+	}
+	type want struct {
+		out []int
+	}
+	for _, test := range []struct {
+		name string
+		args args
+		want want
+	}{{
+		name: "passes the first n items",
+		args: args{
+			n:  3,
+			in: []int{1, 2, 3, 4, 5},
+		},
+		want: want{
+			out: []int{1, 2, 3},
+		},
+	}, {
+		name: "passes everything if in closes before n",
+		args: args{
+			n:  10,
+			in: []int{1, 2, 3},
+		},
+		want: want{
+			out: []int{1, 2, 3},
+		},
+	}, {
+		name: "passes nothing when n is 0",
+		args: args{
+			n:  0,
+			in: []int{1, 2, 3},
+		},
+		want: want{
+			out: nil,
+		},
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var outs []int
+			for o := range Take(context.Background(), test.args.n, Emit(test.args.in...)) {
+				outs = append(outs, o)
+			}
+
+			if !reflect.DeepEqual(test.want.out, outs) {
+				t.Errorf("out = %+v, want %+v", outs, test.want.out)
+			}
+		})
+	}
+}
+
+func TestTakeWhile(t *testing.T) {
+	t.Parallel()
+
+	var outs []int
+	for o := range TakeWhile(context.Background(), func(i int) bool { return i < 3 }, Emit(1, 2, 3, 1, 2)) {
+		outs = append(outs, o)
+	}
+
+	if want := []int{1, 2}; !reflect.DeepEqual(want, outs) {
+		t.Errorf("out = %+v, want %+v", outs, want)
+	}
+}
+
+func TestTakeUntil(t *testing.T) {
+	t.Parallel()
+
+	const maxTestDuration = time.Second
+
+	// Emit one int every 1/10th of the test
+	in := make(chan int)
+	go func() {
+		defer close(in)
+		for i := 1; i <= 5; i++ {
+			in <- i
+			time.Sleep(maxTestDuration / 10)
+		}
+	}()
+
+	var outs []int
+	for o := range TakeUntil(context.Background(), time.After(maxTestDuration/4), in) {
+		outs = append(outs, o)
+	}
+
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(want, outs) {
+		t.Errorf("out = %+v, want %+v", outs, want)
+	}
+}
+
+func TestTakeStalled(t *testing.T) {
+	t.Parallel()
+
+	for _, n := range []int{0, 3} {
+		n := n
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			t.Parallel()
+
+			// The in channel has n items and then stalls without closing
+			in := make(chan int, n)
+			for i := 1; i <= n; i++ {
+				in <- i
+			}
+			defer close(in)
+
+			// The out channel closes once n items are taken, without waiting for another one
+			outs := pipelinetest.Collect(t, Take(context.Background(), n, in), time.Second)
+			if len(outs) != n {
+				t.Errorf("out = %+v, want %d items", outs, n)
+			}
+		})
+	}
+}
+
+func TestTakeCanceled(t *testing.T) {
+	t.Parallel()
+
+	// Nothing reads from the out channel
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan int)
+	out := Take(ctx, 3, in)
+	in <- 1
+	cancel()
+
+	// The rest of the in channel is drained
+	in <- 2
+	close(in)
+	for range out {
+	}
+}
+
+func TestLimit(t *testing.T) {
+	t.Parallel()
+
+	const maxTestDuration = time.Second
+
+	// Emit numbers forever from upstream
+	var upstream context.Context
+	limited := Limit(context.Background(), 3, func(ctx context.Context) <-chan int {
+		upstream = ctx
+		var count int
+		return Emitter(ctx, func() int {
+			count++
+			return count
+		})
+	})
+
+	var outs []int
+	for o := range limited {
+		outs = append(outs, o)
+	}
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(want, outs) {
+		t.Errorf("out = %+v, want %+v", outs, want)
+	}
+
+	// Expect upstream to be canceled once Limit is satisfied
+	select {
+	case <-upstream.Done():
+	case <-time.After(maxTestDuration):
+		t.Fatal("upstream was not canceled")
+	}
+}
+
+func TestLimitStalled(t *testing.T) {
+	t.Parallel()
+
+	// The source sends 3 items and then waits to be canceled, so a fourth item is never received
+	limited := Limit(context.Background(), 3, func(ctx context.Context) <-chan int {
+		out := make(chan int, 3)
+		out <- 1
+		out <- 2
+		out <- 3
+		go func() {
+			defer close(out)
+			<-ctx.Done()
+		}()
+		return out
+	})
+
+	if outs := pipelinetest.Collect(t, limited, time.Second); !reflect.DeepEqual(outs, []int{1, 2, 3}) {
+		t.Errorf("out = %+v, want [1 2 3]", outs)
+	}
+}
@@ -0,0 +1,53 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+)
+
+// Throttle passes the first item of each key from `in <-chan Item` to the out channel,
+// then drops every other item with the same key that is received within `duration`.
+// Items are keyed by `keyFn`, so each key is throttled independently. Use a `keyFn` that returns a constant to throttle all items together.
+// If the context is canceled, the throttle is no longer applied.
+func Throttle[Item any, Key comparable](ctx context.Context, duration time.Duration, keyFn func(Item) Key, in <-chan Item, opts ...Option) <-chan Item {
+	c := newConfig("Throttle", opts)
+	out := make(chan Item)
+	connect(c, out, in)
+	goStage(ctx, c.name, c.kind, func() {
+		defer c.closed()
+		defer close(out)
+		// windows holds the time each key's window ends
+		windows := make(map[Key]time.Time)
+		lastSweep := c.clock.Now()
+		for {
+			i, open := receive(c.node, in)
+			if !open {
+				return
+			}
+			c.metrics.In(c.name)
+			if isDone(ctx) {
+				deliver(c.node, out, i)
+				c.metrics.Out(c.name)
+				continue
+			}
+			now := c.clock.Now()
+			key := keyFn(i)
+			if end, ok := windows[key]; ok && now.Before(end) {
+				continue
+			}
+			windows[key] = now.Add(duration)
+			// Forget the windows that have ended once in a while, so windows does not grow forever
+			if now.Sub(lastSweep) >= duration {
+				for k, end := range windows {
+					if !now.Before(end) {
+						delete(windows, k)
+					}
+				}
+				lastSweep = now
+			}
+			deliver(c.node, out, i)
+			c.metrics.Out(c.name)
+		}
+	})
+	return out
+}
@@ -0,0 +1,90 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestThrottle(t *testing.T) {
+	t.Parallel()
+
+	const maxTestDuration = time.Second
+	type args struct {
+		duration   time.Duration
+		ctxTimeout time.Duration
+		steps      []pingStep
+	}
+	type want struct {
+		out []ping
+	}
+	for _, test := range []struct {
+		name string
+		args args
+		want want
+	}{{
+		name: "passes the first item of each duration",
+		args: args{
+			duration:   maxTestDuration / 10,
+			ctxTimeout: maxTestDuration,
+			steps: []pingStep{
+				{ping: ping{"a", 1}},
+				{ping: ping{"a", 2}},
+				{ping: ping{"a", 3}},
+				{wait: maxTestDuration / 5, ping: ping{"a", 4}},
+				{ping: ping{"a", 5}},
+			},
+		},
+		want: want{
+			out: []ping{{"a", 1}, {"a", 4}},
+		},
+	}, {
+		name: "each key is throttled independently",
+		args: args{
+			duration:   maxTestDuration,
+			ctxTimeout: maxTestDuration,
+			steps: []pingStep{
+				{ping: ping{"a", 1}},
+				{ping: ping{"b", 1}},
+				{ping: ping{"a", 2}},
+				{ping: ping{"b", 2}},
+			},
+		},
+		want: want{
+			out: []ping{{"a", 1}, {"b", 1}},
+		},
+	}, {
+		name: "throttle is not applied when the context is canceled",
+		args: args{
+			duration:   maxTestDuration,
+			ctxTimeout: maxTestDuration / 10,
+			steps: []pingStep{
+				{ping: ping{"a", 1}},
+				{ping: ping{"a", 2}},
+				{wait: maxTestDuration / 5, ping: ping{"a", 3}},
+				{ping: ping{"a", 4}},
+			},
+		},
+		want: want{
+			out: []ping{{"a", 1}, {"a", 3}, {"a", 4}},
+		},
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithTimeout(context.Background(), test.args.ctxTimeout)
+			defer cancel()
+
+			var outs []ping
+			for p := range Throttle(ctx, test.args.duration, func(p ping) string { return p.courier }, sendPings(test.args.steps)) {
+				outs = append(outs, p)
+			}
+
+			if !reflect.DeepEqual(test.want.out, outs) {
+				t.Errorf("out = %+v, want %+v", outs, test.want.out)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package pipeline
+
+import "context"
+
+// Tracer starts spans. Implement Tracer to trace the items of a pipeline with a tracing system like OpenTelemetry.
+type Tracer interface {
+	// Start starts a span as a child of `parent`, the SpanContext of a span started by the same Tracer,
+	// or as a child of the span in ctx if `parent` is nil, or as a new trace if there is no span in ctx either.
+	// It returns a copy of ctx that carries the new span.
+	Start(ctx context.Context, parent any, name string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer
+type Span interface {
+	// SetAttribute sets an attribute of the span.
+	SetAttribute(key string, value any)
+
+	// SetError marks the span as failed.
+	SetError(err error)
+
+	// End ends the span.
+	End()
+
+	// SpanContext identifies the span, so it can travel with an item to the next stage.
+	// Its type is up to the Tracer, like the `trace.SpanContext` of OpenTelemetry.
+	SpanContext() any
+}
+
+// Traced carries an item through the channels of a pipeline together with the context of its latest span,
+// so a single trace covers the whole journey of the item
+type Traced[Item any] struct {
+	// SpanContext is returned by `Span.SpanContext`, or nil before the item is traced by its first stage
+	SpanContext any
+	Item        Item
+}
+
+// TraceItems wraps the items from `in <-chan Item` in a `Traced[Item]`.
+// The first span of every item is a child of the span in the context of the stage that traces it, if there is one.
+func TraceItems[Item any](ctx context.Context, in <-chan Item) <-chan Traced[Item] {
+	out := make(chan Traced[Item])
+	goStage(ctx, "TraceItems", "TraceItems", func() {
+		defer close(out)
+		for i := range in {
+			out <- Traced[Item]{Item: i}
+		}
+	})
+	return out
+}
+
+// UntraceItems unwraps the items from `in <-chan Traced[Item]`
+func UntraceItems[Item any](in <-chan Traced[Item]) <-chan Item {
+	out := make(chan Item)
+	goStage(context.Background(), "UntraceItems", "UntraceItems", func() {
+		defer close(out)
+		for i := range in {
+			out <- i.Item
+		}
+	})
+	return out
+}
+
+type trace[Input, Output any] struct {
+	name   string
+	tracer Tracer
+	p      Processor[Input, Output]
+}
+
+func (t *trace[Input, Output]) Process(ctx context.Context, i Traced[Input]) (Traced[Output], error) {
+	ctx, span := t.tracer.Start(ctx, i.SpanContext, t.name)
+	defer span.End()
+	span.SetAttribute(stageAttribute, t.name)
+	o, err := t.p.Process(ctx, i.Item)
+	if err != nil {
+		span.SetError(err)
+	}
+	return Traced[Output]{span.SpanContext(), o}, err
+}
+
+func (t *trace[Input, Output]) Cancel(i Traced[Input], err error) {
+	t.p.Cancel(i.Item, err)
+}
+
+// Trace wraps a Processor so that every call to `Processor.Process` gets a span named `name`.
+// The span is a child of the span that travels with the input, and it travels on with the output.
+// The span is in the context the wrapped processor is called with, so the processors it calls,
+// like the ones called by Join, Apply and Sequence, can be traced as its children with TraceStep.
+func Trace[Input, Output any](name string, tracer Tracer, p Processor[Input, Output]) Processor[Traced[Input], Traced[Output]] {
+	return &trace[Input, Output]{name, tracer, p}
+}
+
+type traceStep[Input, Output any] struct {
+	name   string
+	tracer Tracer
+	p      Processor[Input, Output]
+}
+
+func (t *traceStep[Input, Output]) Process(ctx context.Context, i Input) (Output, error) {
+	ctx, span := t.tracer.Start(ctx, nil, t.name)
+	defer span.End()
+	span.SetAttribute(stageAttribute, t.name)
+	o, err := t.p.Process(ctx, i)
+	if err != nil {
+		span.SetError(err)
+	}
+	return o, err
+}
+
+func (t *traceStep[Input, Output]) Cancel(i Input, err error) {
+	t.p.Cancel(i, err)
+}
+
+// TraceStep wraps a Processor so that every call to `Processor.Process` gets a span named `name`, as a child of the span in its context.
+// Wrap the processors passed to Join, Apply or Sequence with TraceStep to trace each of them within the span of a processor wrapped with Trace.
+func TraceStep[Input, Output any](name string, tracer Tracer, p Processor[Input, Output]) Processor[Input, Output] {
+	return &traceStep[Input, Output]{name, tracer, p}
+}
+
+// stageAttribute is the span attribute that holds the name of the traced stage or step
+const stageAttribute = "pipeline.stage"
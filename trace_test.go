@@ -0,0 +1,153 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// recordedSpan is a span recorded by recordingTracer
+type recordedSpan struct {
+	name   string
+	parent string
+	attrs  map[string]any
+	err    error
+	ended  bool
+}
+
+// spanKey is the context key of the name of the current span
+type spanKey struct{}
+
+// recordingTracer records its spans in memory
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (r *recordingTracer) Start(ctx context.Context, parent any, name string) (context.Context, Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	path, ok := parent.(string)
+	if !ok {
+		path, _ = ctx.Value(spanKey{}).(string)
+	}
+	span := &recordedSpan{name: name, parent: path, attrs: make(map[string]any)}
+	r.spans = append(r.spans, span)
+	return context.WithValue(ctx, spanKey{}, path+"/"+name), &recordingSpan{r, span}
+}
+
+// recordingSpan records into a recordedSpan
+type recordingSpan struct {
+	r    *recordingTracer
+	span *recordedSpan
+}
+
+func (s *recordingSpan) SetAttribute(key string, value any) {
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	s.span.attrs[key] = value
+}
+
+func (s *recordingSpan) SetError(err error) {
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	s.span.err = err
+}
+
+func (s *recordingSpan) End() {
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	s.span.ended = true
+}
+
+// SpanContext is the path of the span
+func (s *recordingSpan) SpanContext() any {
+	return s.span.parent + "/" + s.span.name
+}
+
+func TestTrace(t *testing.T) {
+	t.Parallel()
+
+	inc := NewProcessor(func(_ context.Context, i int) (int, error) {
+		return i + 1, nil
+	}, func(int, error) {})
+	fail := NewProcessor(func(_ context.Context, i int) (int, error) {
+		return 0, errors.New("failed")
+	}, func(int, error) {})
+
+	type span struct {
+		path string
+		err  bool
+	}
+	for _, test := range []struct {
+		name  string
+		first func(Tracer) Processor[int, int]
+		want  []span
+	}{{
+		name: "spans follow the item through stages and processors",
+		first: func(tracer Tracer) Processor[int, int] {
+			return Sequence(TraceStep("a", tracer, inc), TraceStep("b", tracer, Join(TraceStep("c", tracer, inc), inc)))
+		},
+		want: []span{
+			{path: "/first"},
+			{path: "/first/a"},
+			{path: "/first/b"},
+			{path: "/first/b/c"},
+			{path: "/first/second"},
+		},
+	}, {
+		name: "spans are marked with errors",
+		first: func(tracer Tracer) Processor[int, int] {
+			return Sequence(TraceStep("a", tracer, inc), TraceStep("b", tracer, fail))
+		},
+		want: []span{
+			{path: "/first", err: true},
+			{path: "/first/a"},
+			{path: "/first/b", err: true},
+		},
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			tracer := &recordingTracer{}
+			p := TraceItems(context.Background(), Emit(1))
+			p = Process(context.Background(), Trace("first", tracer, test.first(tracer)), p)
+			p = Process(context.Background(), Trace("second", tracer, inc), p)
+			Drain(p)
+
+			var got []span
+			for _, s := range tracer.spans {
+				if !s.ended {
+					t.Errorf("span %s/%s was not ended", s.parent, s.name)
+				}
+				if stage := s.attrs[stageAttribute]; stage != s.name {
+					t.Errorf("span %s/%s has stage %v", s.parent, s.name, stage)
+				}
+				got = append(got, span{
+					path: s.parent + "/" + s.name,
+					err:  s.err != nil,
+				})
+			}
+			sort.Slice(got, func(i, j int) bool { return got[i].path < got[j].path })
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("spans = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUntraceItems(t *testing.T) {
+	t.Parallel()
+
+	var outs []int
+	for o := range UntraceItems(TraceItems(context.Background(), Emit(1, 2, 3))) {
+		outs = append(outs, o)
+	}
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(want, outs) {
+		t.Errorf("out = %+v, want %+v", outs, want)
+	}
+}
@@ -0,0 +1,133 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// Valve pauses and resumes the stages created with Pausable.
+// It is safe for concurrent use, so it can be toggled from an admin endpoint while the pipeline runs, see ServeHTTP.
+type Valve struct {
+	mu     sync.Mutex
+	paused bool
+	// open is closed while the valve is not paused
+	open chan struct{}
+}
+
+// NewValve creates a Valve that is not paused
+func NewValve() *Valve {
+	open := make(chan struct{})
+	close(open)
+	return &Valve{open: open}
+}
+
+// Pause stops the stages of the valve from reading from their in channels
+func (v *Valve) Pause() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if !v.paused {
+		v.paused = true
+		v.open = make(chan struct{})
+	}
+}
+
+// Resume lets the stages of the valve read from their in channels again
+func (v *Valve) Resume() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.paused {
+		v.paused = false
+		close(v.open)
+	}
+}
+
+// Paused returns true if the valve is paused
+func (v *Valve) Paused() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.paused
+}
+
+// opened returns a chan that is closed once the valve is not paused
+func (v *Valve) opened() <-chan struct{} {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.open
+}
+
+// valveState is the JSON body of the requests and responses of Valve.ServeHTTP
+type valveState struct {
+	Paused bool `json:"paused"`
+}
+
+// ServeHTTP responds with whether the valve is paused as JSON, e.g. `{"paused":true}`.
+// POST the same JSON to pause or resume it.
+func (v *Valve) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var state valveState
+		if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if state.Paused {
+			v.Pause()
+		} else {
+			v.Resume()
+		}
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(valveState{Paused: v.Paused()}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Pausable passes the items from `in <-chan Item` to the out channel while the valve is not paused.
+// While it is paused, nothing is read from the in channel, so the upstream stages block with their buffers intact
+// and nothing is canceled. An item that was already read when the valve is paused is still passed on.
+// If the context is canceled, the valve is no longer applied, so the pipeline can shut down.
+func Pausable[Item any](ctx context.Context, valve *Valve, in <-chan Item, opts ...Option) <-chan Item {
+	c := newConfig("Pausable", opts)
+	out := make(chan Item)
+	connect(c, out, in)
+	goStage(ctx, c.name, c.kind, func() {
+		defer c.closed()
+		defer close(out)
+		for {
+			waitForValve(ctx, c, valve)
+			i, open := receive(c.node, in)
+			if !open {
+				return
+			}
+			c.metrics.In(c.name)
+			// The valve may have been paused while waiting for the item
+			waitForValve(ctx, c, valve)
+			deliver(c.node, out, i)
+			c.metrics.Out(c.name)
+		}
+	})
+	return out
+}
+
+// waitForValve blocks while the valve is paused, unless the context is canceled
+func waitForValve(ctx context.Context, c *config, valve *Valve) {
+	open := valve.opened()
+	select {
+	case <-open:
+		return
+	default:
+	}
+	c.node.pause(true)
+	defer c.node.pause(false)
+	select {
+	case <-open:
+	case <-ctx.Done():
+	}
+}
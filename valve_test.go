@@ -0,0 +1,95 @@
+package pipeline
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline/v2/pipelinetest"
+)
+
+func TestPausable(t *testing.T) {
+	t.Parallel()
+
+	g := NewGraph()
+	valve := NewValve()
+	out := Pausable(context.Background(), valve, Emit(1, 2, 3, 4), WithGraph(g))
+	if got := pipelinetest.CollectN(t, out, 1, time.Second); got[0] != 1 {
+		t.Errorf("got %v, want 1", got)
+	}
+
+	// Nothing but the item that was already read is passed on while the valve is paused
+	valve.Pause()
+	if !valve.Paused() {
+		t.Error("valve is not paused")
+	}
+	var got []int
+	waitFor(t, func() bool {
+		select {
+		case i := <-out:
+			got = append(got, i)
+		default:
+		}
+		return g.Status()[0].Paused
+	})
+	if len(got) > 1 {
+		t.Errorf("got %v while paused", got)
+	}
+	assertNothingReceived(t, out)
+
+	// Everything is passed on after it is resumed
+	valve.Resume()
+	if got = append(got, pipelinetest.Collect(t, out, time.Second)...); !reflect.DeepEqual(got, []int{2, 3, 4}) {
+		t.Errorf("got %v, want [2 3 4]", got)
+	}
+	if valve.Paused() {
+		t.Error("valve is paused")
+	}
+}
+
+func TestPausableContextCanceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	valve := NewValve()
+	valve.Pause()
+	out := Pausable(ctx, valve, Emit(1, 2, 3))
+
+	// The valve no longer applies once the context is canceled
+	cancel()
+	if got := pipelinetest.Collect(t, out, time.Second); !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Errorf("got %v, want [1 2 3]", got)
+	}
+}
+
+func TestValveServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	valve := NewValve()
+	for _, test := range []struct {
+		method string
+		body   string
+		code   int
+		want   string
+	}{
+		{http.MethodGet, "", http.StatusOK, `{"paused":false}`},
+		{http.MethodPost, `{"paused":true}`, http.StatusOK, `{"paused":true}`},
+		{http.MethodGet, "", http.StatusOK, `{"paused":true}`},
+		{http.MethodPost, `not json`, http.StatusBadRequest, ""},
+		{http.MethodPost, `{"paused":false}`, http.StatusOK, `{"paused":false}`},
+		{http.MethodPut, "", http.StatusMethodNotAllowed, ""},
+	} {
+		res := httptest.NewRecorder()
+		valve.ServeHTTP(res, httptest.NewRequest(test.method, "/", strings.NewReader(test.body)))
+		if res.Code != test.code {
+			t.Errorf("%s %s: status code = %d, want %d", test.method, test.body, res.Code, test.code)
+		}
+		if got := strings.TrimSpace(res.Body.String()); test.want != "" && got != test.want {
+			t.Errorf("%s %s: body = %s, want %s", test.method, test.body, got, test.want)
+		}
+	}
+}
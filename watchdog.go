@@ -0,0 +1,206 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// ErrStalled is the error a stage stalled with.
+// A stage stalls when it has pending input but makes no progress for a while,
+// usually because the stage after it stopped reading its out channel.
+type ErrStalled struct {
+	// Stage is the name of the stage that stalled
+	Stage string
+	// Kind is the kind of the stage that stalled, like Process or Buffer
+	Kind string
+	// Stuck is the number of goroutines of the stage that are stuck
+	Stuck int64
+	// Idle is how long the stage had made no progress for
+	Idle time.Duration
+}
+
+func (e ErrStalled) Error() string {
+	return fmt.Sprintf("stage %s (%s) stalled: %d goroutines made no progress for %s", e.Stage, e.Kind, e.Stuck, e.Idle)
+}
+
+// Watch watches the stages of the graph and sends an ErrStalled to the returned channel
+// every time a stage with pending input makes no progress for `after`.
+// A stage has pending input when it is processing an item, waiting to send an item or has items waiting in its in channels.
+// The stages are checked every quarter of `after`, with the clock set WithClock.
+// A stage is reported once per stall, and again only after it has made progress.
+// Nothing is reported for a stage paused by a Valve, or for the stages before it, which are meant to block, and after it, which it holds up.
+// The rest of the graph is still watched.
+// The returned channel is closed when the context is canceled.
+// Watch panics if `after` is not positive.
+func Watch(ctx context.Context, g *Graph, after time.Duration, opts ...Option) <-chan ErrStalled {
+	if after <= 0 {
+		panic(fmt.Sprintf("pipeline: Watch needs a positive duration to detect stalls after, not %s", after))
+	}
+	c := newConfig("Watch", opts)
+	out := make(chan ErrStalled)
+	goStage(ctx, c.name, c.kind, func() {
+		defer close(out)
+		started := c.clock.Now()
+		// progress is when each node was first seen with its last activity
+		progress := make(map[*node]activity)
+		reported := make(map[*node]time.Time)
+		interval := max(after/4, 1)
+		timer := c.clock.NewTimer(interval)
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-timer.C():
+				skipped := g.paused()
+				for _, n := range g.watched() {
+					if skipped[n] {
+						// The stage only starts stalling once it is resumed
+						progress[n] = activity{last: atomic.LoadInt64(&n.lastActivity), since: now}
+						delete(reported, n)
+						continue
+					}
+					a := progress[n].observe(n, now, started)
+					progress[n] = a
+					err, stalled := n.stalled(now, a.since, after)
+					if !stalled {
+						delete(reported, n)
+						continue
+					}
+					if at, ok := reported[n]; ok && at.Equal(a.since) {
+						continue
+					}
+					reported[n] = a.since
+					select {
+					case <-ctx.Done():
+						return
+					case out <- err:
+					}
+				}
+				// The clock only runs on for the next check once this one is done
+				timer.Reset(interval)
+			}
+		}
+	})
+	return out
+}
+
+// activity is the last activity of a node, and since when the watchdog has seen it
+type activity struct {
+	last  int64
+	since time.Time
+}
+
+// observe returns the activity of the node at `now`.
+// Nodes are idle since the watch `started`, until their last activity changes while they are watched.
+func (a activity) observe(n *node, now, started time.Time) activity {
+	last := atomic.LoadInt64(&n.lastActivity)
+	switch {
+	case a.since.IsZero():
+		return activity{last: last, since: started}
+	case last != a.last:
+		return activity{last: last, since: now}
+	default:
+		return a
+	}
+}
+
+// WithWatchdog returns a copy of the context that is canceled when a stage of the graph stalls for `after`.
+// `context.Cause` returns the ErrStalled of the stage that stalled.
+// Pass the returned context to the stages of the graph to cancel the pipeline when it stalls.
+func WithWatchdog(ctx context.Context, g *Graph, after time.Duration, opts ...Option) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	goStage(ctx, "WithWatchdog", "WithWatchdog", func() {
+		if err, ok := <-Watch(ctx, g, after, opts...); ok {
+			cancel(err)
+		}
+	})
+	return ctx, func() { cancel(nil) }
+}
+
+// watched returns the nodes of the graph
+func (g *Graph) watched() []*node {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]*node(nil), g.nodes...)
+}
+
+// paused returns the nodes that are paused, along with the nodes before and after them
+func (g *Graph) paused() map[*node]bool {
+	var paused []*node
+	for _, n := range g.watched() {
+		if atomic.LoadInt64(&n.paused) == 1 {
+			paused = append(paused, n)
+		}
+	}
+	skipped := make(map[*node]bool)
+	for _, nodes := range [][]*node{paused, g.upstream(paused), g.downstream(paused)} {
+		for _, n := range nodes {
+			skipped[n] = true
+		}
+	}
+	return skipped
+}
+
+// upstream returns the nodes that the nodes in `from` read from, directly or through other nodes
+func (g *Graph) upstream(from []*node) []*node {
+	nodes := g.watched()
+	producers := make(map[any]*node, len(nodes))
+	for _, n := range nodes {
+		if out := n.output(); out != nil {
+			producers[out] = n
+		}
+	}
+	seen := make(map[*node]bool)
+	var found []*node
+	for len(from) > 0 {
+		n := from[0]
+		from = from[1:]
+		for _, in := range n.inputs() {
+			if p, ok := producers[in]; ok && !seen[p] {
+				seen[p] = true
+				found = append(found, p)
+				from = append(from, p)
+			}
+		}
+	}
+	return found
+}
+
+// stalled returns an ErrStalled if the node has pending input and made no progress for `after` since `since`
+func (n *node) stalled(now, since time.Time, after time.Duration) (ErrStalled, bool) {
+	s := n.status()
+	idle := now.Sub(since)
+	if idle < after || !(s.InFlight > 0 || s.BlockedOnSend > 0 || n.pending() > 0) {
+		return ErrStalled{}, false
+	}
+	// Goroutines waiting to send are stuck, as are the ones holding the semaphore of a concurrent stage.
+	// Otherwise the stage's only goroutine is stuck processing or not reading its input.
+	stuck := s.BlockedOnSend
+	if concurrent := int64(s.Concurrent); concurrent > stuck {
+		stuck = concurrent
+	}
+	if stuck == 0 {
+		stuck = 1
+	}
+	return ErrStalled{
+		Stage: s.Name,
+		Kind:  s.Kind,
+		Stuck: stuck,
+		Idle:  idle,
+	}, true
+}
+
+// pending returns the number of items waiting in the in channels of the node
+func (n *node) pending() int {
+	var pending int
+	for _, in := range n.inputs() {
+		if in := reflect.ValueOf(in); in.Kind() == reflect.Chan && !in.IsNil() {
+			pending += in.Len()
+		}
+	}
+	return pending
+}
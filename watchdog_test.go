@@ -0,0 +1,205 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline/v2/pipelinetest"
+)
+
+// check advances the clock of a watchdog to its next check, and waits until the check is done
+func check(t *testing.T, clock *pipelinetest.FakeClock, after time.Duration) {
+	t.Helper()
+	clock.BlockUntil(1)
+	clock.Advance(after / 4)
+	// The watchdog waits for the next check once it reported what stalled
+	waitFor(t, func() bool { return clock.Waiting() == 1 })
+}
+
+// assertNoStall fails the test if a stall is waiting to be received
+func assertNoStall(t *testing.T, stalls <-chan ErrStalled) {
+	t.Helper()
+	select {
+	case err := <-stalls:
+		t.Errorf("stall reported: %s", err)
+	default:
+	}
+}
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+
+	const after = time.Minute
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The out chan of process is never read, while idle never receives anything
+	g := NewGraph()
+	Process[int, int](ctx, &mockProcessor[int]{}, Emit(1, 2, 3), WithName("process"), WithGraph(g))
+	Process[int, int](ctx, &mockProcessor[int]{}, make(chan int), WithName("idle"), WithGraph(g))
+	waitFor(t, func() bool { return g.Status()[0].BlockedOnSend == 1 })
+
+	clock := pipelinetest.NewFakeClock(time.Now())
+	stalls := Watch(ctx, g, after, WithClock(clock))
+	for i := 0; i < 3; i++ {
+		check(t, clock, after)
+		assertNoStall(t, stalls)
+	}
+
+	clock.BlockUntil(1)
+	clock.Advance(after / 4)
+	select {
+	case err := <-stalls:
+		want := ErrStalled{Stage: "process", Kind: "Process", Stuck: 1, Idle: after}
+		if err != want {
+			t.Errorf("stalled = %+v, want %+v", err, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stall was not detected")
+	}
+
+	// The stall is only reported once
+	for i := 0; i < 8; i++ {
+		check(t, clock, after)
+		assertNoStall(t, stalls)
+	}
+
+	// The channel closes when the context is canceled
+	cancel()
+	for range stalls {
+	}
+}
+
+func TestWithWatchdog(t *testing.T) {
+	t.Parallel()
+
+	const after = time.Minute
+	g := NewGraph()
+	clock := pipelinetest.NewFakeClock(time.Now())
+	ctx, cancel := WithWatchdog(context.Background(), g, after, WithClock(clock))
+	defer cancel()
+
+	// A consumer that reads an item every half of `after` keeps the pipeline going
+	out := Process[int, int](ctx, &mockProcessor[int]{}, Emit(1, 2, 3), WithName("process"), WithGraph(g))
+	waitFor(t, func() bool { return g.Status()[0].BlockedOnSend == 1 })
+	for n := int64(1); n <= 2; n++ {
+		check(t, clock, after)
+		check(t, clock, after)
+		<-out
+		waitFor(t, func() bool {
+			s := g.Status()[0]
+			return s.Out == n && s.BlockedOnSend == 1
+		})
+	}
+	if err := ctx.Err(); err != nil {
+		t.Fatalf("pipeline canceled while making progress: %s", err)
+	}
+
+	// A consumer that stops reading stalls it once the watchdog saw its last progress and `after` passed
+	for i := 0; i < 4; i++ {
+		check(t, clock, after)
+	}
+	if err := ctx.Err(); err != nil {
+		t.Fatalf("pipeline canceled before it stalled for %s: %s", after, err)
+	}
+	clock.BlockUntil(1)
+	clock.Advance(after / 4)
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("pipeline was not canceled")
+	}
+	var stalled ErrStalled
+	if err := context.Cause(ctx); !errors.As(err, &stalled) || stalled.Stage != "process" || stalled.Idle != after {
+		t.Errorf("cause = %v, want process to stall for %s", err, after)
+	}
+	Drain(out)
+}
+
+func TestWatchPaused(t *testing.T) {
+	t.Parallel()
+
+	const after = time.Minute
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The buffer blocks because the valve is paused, not because it stalled
+	g := NewGraph()
+	valve := NewValve()
+	valve.Pause()
+	Pausable(ctx, valve, Buffer(1, Emit(1, 2, 3), WithGraph(g)), WithGraph(g))
+	waitFor(t, func() bool {
+		s := g.Status()
+		return s[0].BlockedOnSend == 1 && s[1].Paused
+	})
+
+	clock := pipelinetest.NewFakeClock(time.Now())
+	stalls := Watch(ctx, g, after, WithClock(clock))
+	for i := 0; i < 8; i++ {
+		check(t, clock, after)
+		assertNoStall(t, stalls)
+	}
+}
+
+func TestWatchDuration(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The watchdog checks at least every nanosecond
+	stalls := Watch(ctx, NewGraph(), time.Nanosecond)
+	cancel()
+	for range stalls {
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Watch did not panic without a duration")
+		}
+	}()
+	Watch(ctx, NewGraph(), 0)
+}
+
+func TestWatchPausedBranch(t *testing.T) {
+	t.Parallel()
+
+	const after = time.Minute
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The buffer blocks because the valve is paused, while the out chan of process is never read
+	g := NewGraph()
+	valve := NewValve()
+	valve.Pause()
+	Pausable(ctx, valve, Buffer(1, Emit(1, 2, 3), WithGraph(g)), WithGraph(g))
+	Process[int, int](ctx, &mockProcessor[int]{}, Emit(1, 2, 3), WithName("process"), WithGraph(g))
+	waitFor(t, func() bool {
+		s := g.Status()
+		return s[0].BlockedOnSend == 1 && s[1].Paused && s[2].BlockedOnSend == 1
+	})
+
+	// Only the stage that is not held up by the valve is reported
+	clock := pipelinetest.NewFakeClock(time.Now())
+	stalls := Watch(ctx, g, after, WithClock(clock))
+	for i := 0; i < 3; i++ {
+		check(t, clock, after)
+		assertNoStall(t, stalls)
+	}
+	clock.BlockUntil(1)
+	clock.Advance(after / 4)
+	select {
+	case err := <-stalls:
+		if err.Stage != "process" {
+			t.Errorf("stalled = %+v, want process to stall", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stall was not detected")
+	}
+	for i := 0; i < 8; i++ {
+		check(t, clock, after)
+		assertNoStall(t, stalls)
+	}
+}
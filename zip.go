@@ -0,0 +1,69 @@
+package pipeline
+
+import "context"
+
+// Pair holds one item from each of two channels
+type Pair[A, B any] struct {
+	First  A
+	Second B
+}
+
+// Zip pairs the items of `a <-chan A` and `b <-chan B` positionally and passes each `Pair[A, B]` to the out channel.
+// The out channel is closed as soon as either input is closed or the `Context` is canceled.
+// Anything remaining in the inputs after that is drained, so the upstream stages never block.
+func Zip[A, B any](ctx context.Context, a <-chan A, b <-chan B, opts ...Option) <-chan Pair[A, B] {
+	c := newConfig("Zip", opts)
+	out := make(chan Pair[A, B])
+	connect(c, out, a, b)
+	goStage(ctx, c.name, c.kind, func() {
+		defer c.closed()
+		defer close(out)
+		// Unblock the upstream stages once we stop reading
+		defer func() {
+			go Drain(a)
+			go Drain(b)
+		}()
+		for {
+			pair, ok := zipNext(ctx, c, a, b)
+			if !ok || !send(ctx, c.node, pair, out) {
+				return
+			}
+			c.metrics.Out(c.name)
+		}
+	})
+	return out
+}
+
+// zipNext waits for one item from each input, in any order.
+// It returns false if an input is closed or the context is canceled first.
+// The first item of a pair is held until the second one is received.
+func zipNext[A, B any](ctx context.Context, c *config, a <-chan A, b <-chan B) (Pair[A, B], bool) {
+	var pair Pair[A, B]
+	var held int64
+	defer func() { c.node.hold(-held) }()
+	c.node.blockedOnReceive(1)
+	defer c.node.blockedOnReceive(-1)
+	for a != nil || b != nil {
+		select {
+		case <-ctx.Done():
+			return pair, false
+		case i, open := <-a:
+			if !open {
+				return pair, false
+			}
+			c.metrics.In(c.name)
+			c.node.hold(1)
+			held++
+			pair.First, a = i, nil
+		case i, open := <-b:
+			if !open {
+				return pair, false
+			}
+			c.metrics.In(c.name)
+			c.node.hold(1)
+			held++
+			pair.Second, b = i, nil
+		}
+	}
+	return pair, true
+}
@@ -0,0 +1,23 @@
+package pipeline_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/deliveryhero/pipeline/v2"
+)
+
+func ExampleZip() {
+	ids := pipeline.Emit(1, 2, 3)
+	names := pipeline.Emit("one", "two", "three")
+
+	// Pair every id with the name at the same position
+	for pair := range pipeline.Zip(context.Background(), ids, names) {
+		fmt.Printf("%d: %s\n", pair.First, pair.Second)
+	}
+
+	// Output:
+	// 1: one
+	// 2: two
+	// 3: three
+}
@@ -0,0 +1,119 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestZip(t *testing.T) {
+	t.Parallel()
+
+	const maxTestDuration = time.Second
+	type args struct {
+		a          []int
+		b          []string
+		ctxTimeout time.Duration
+	}
+	type want struct {
+		out  []Pair[int, string]
+		open bool
+	}
+	for _, test := range []struct {
+		name string
+		args args
+		want want
+	}{{
+		name: "pairs items positionally",
+		args: args{
+			a:          []int{1, 2, 3},
+			b:          []string{"a", "b", "c"},
+			ctxTimeout: maxTestDuration,
+		},
+		want: want{
+			out:  []Pair[int, string]{{1, "a"}, {2, "b"}, {3, "c"}},
+			open: false,
+		},
+	}, {
+		name: "out closes when the shorter input closes",
+		args: args{
+			a:          []int{1, 2, 3, 4, 5},
+			b:          []string{"a", "b"},
+			ctxTimeout: maxTestDuration,
+		},
+		want: want{
+			out:  []Pair[int, string]{{1, "a"}, {2, "b"}},
+			open: false,
+		},
+	}, {
+		name: "out closes when the context is canceled",
+		args: args{
+			a:          []int{1, 2, 3},
+			b:          nil,
+			ctxTimeout: maxTestDuration / 10,
+		},
+		want: want{
+			out:  nil,
+			open: false,
+		},
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithTimeout(context.Background(), test.args.ctxTimeout)
+			defer cancel()
+
+			// b never closes if it has no items, so only the context can close out
+			b := Emit(test.args.b...)
+			if test.args.b == nil {
+				b = make(chan string)
+			}
+
+			zipped := Zip(ctx, Emit(test.args.a...), b)
+			timeout := time.After(maxTestDuration)
+			var outs []Pair[int, string]
+			var isOpen bool
+		loop:
+			for {
+				select {
+				case o, open := <-zipped:
+					isOpen = open
+					if !open {
+						break loop
+					}
+					outs = append(outs, o)
+				case <-timeout:
+					break loop
+				}
+			}
+
+			if test.want.open != isOpen {
+				t.Errorf("open = %t, want %t", isOpen, test.want.open)
+			}
+			if !reflect.DeepEqual(test.want.out, outs) {
+				t.Errorf("out = %+v, want %+v", outs, test.want.out)
+			}
+		})
+	}
+}
+
+func TestZipHeld(t *testing.T) {
+	t.Parallel()
+
+	g := NewGraph()
+	a, b := make(chan int), make(chan string)
+	defer close(a)
+	out := Zip[int, string](context.Background(), a, b, WithGraph(g))
+
+	// The first item of a pair is held until the second one is received
+	a <- 1
+	waitFor(t, func() bool { return g.Status()[0].Held == 1 })
+	b <- "a"
+	if got := <-out; got != (Pair[int, string]{1, "a"}) {
+		t.Errorf("got %v, want {1 a}", got)
+	}
+	waitFor(t, func() bool { return g.Status()[0].Held == 0 })
+	close(b)
+}